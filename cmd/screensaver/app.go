@@ -3,14 +3,15 @@ package main
 import (
 	crypto_rand "crypto/rand"
 	"encoding/binary"
-	"io/fs"
-	"io/ioutil"
+	"fmt"
+	"image"
 	"log"
 	"math/rand"
 	"os"
-	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -24,29 +25,130 @@ import (
 
 var InstallPath string
 var MediaPath string
+var MediaSources []MediaSource
+var MediaExtensions []string
+var MediaSelectionMode SelectionMode
+var MonitorSources MonitorConfigs
+var MediaAspectMatching AspectMatching
+var LibraryPollInterval time.Duration
+var FallbackClip string
+var CrossfadeDuration time.Duration
+var Display DisplayMode
+var SpanFitting SpanFit
+var SpanBezelGaps SpanBezels
+var MirrorTimeout time.Duration
+var SyncThreshold time.Duration
+var VlcLogFilter vlc.LogFilter
 
 type VideoWindowContext struct {
-	mainWindow  *walk.MainWindow
-	videoWidget *VlcVideoWidget
-	MediaPath   string
-	Bounds      declarative.Rectangle
-	Identifier  string
-	Parent      win.HWND
+	mainWindow      *walk.MainWindow
+	videoWidget     *VlcVideoWidget
+	selector        *MediaSelector
+	current         string
+	currentStarted  time.Time
+	Sources         []MediaSource
+	MediaExtensions []string
+	SelectionMode   SelectionMode
+	AspectMatching  AspectMatching
+	History         *PlayHistory
+	Index           *MediaIndex
+	Quarantine      *Quarantine
+	Bounds          declarative.Rectangle
+	Identifier      string
+	Parent          win.HWND
+	// Dispatcher runs work on the thread owning a preview, which has no
+	// walk window to synchronise with.
+	Dispatcher vlc.Dispatcher
+	// Span, if set, lays one video out across all the monitors, with a
+	// view for each if there are bezel gaps to skip.
+	Span  *spanLayout
+	views []*spanView
+	// Mirror, if set, is the group of windows showing the same clips, of
+	// which this is member MirrorMember.
+	Mirror       *mirrorGroup
+	MirrorMember int
 }
 
-func (vmw *VideoWindowContext) getMedia() string {
-	var files []fs.FileInfo
-	files, err := ioutil.ReadDir(vmw.MediaPath)
+// getMedia chooses the next item, or the one after when ahead is set. It may
+// be some time before it plays, as the widget loads it ahead of time.
+func (vmw *VideoWindowContext) getMedia(ahead bool) (MediaItem, error) {
+	var item MediaItem
+	var err error
+	if vmw.Mirror != nil {
+		item, err = vmw.Mirror.Item(vmw.MirrorMember, ahead)
+	} else {
+		item, err = vmw.nextAvailable()
+	}
 	if err != nil {
-		log.Panic(err)
+		return MediaItem{}, fmt.Errorf("%s: choosing media: %w", vmw.Identifier, err)
 	}
 
-	var index = rand.Intn(len(files))
-	var ret = filepath.Join(vmw.MediaPath, files[index].Name())
+	log.Printf("%s: queued %v", vmw.Identifier, item)
 
-	log.Printf("%s: playing file %s", vmw.Identifier, ret)
+	return item, nil
+}
 
-	return ret
+// mediaStarted records that item is now on screen, and that whatever was
+// before it has finished.
+func (vmw *VideoWindowContext) mediaStarted(item MediaItem) {
+	vmw.finishCurrent()
+
+	log.Printf("%s: playing %v", vmw.Identifier, item)
+
+	vmw.current = item.Location
+	vmw.currentStarted = time.Now()
+
+	// Mirrored clips are only counted once, by the first monitor.
+	if vmw.Mirror != nil && vmw.MirrorMember != 0 {
+		vmw.current = ""
+		return
+	}
+	vmw.History.Started(item.Location, vmw.currentStarted)
+}
+
+// mediaFailed records that a clip couldn't be played, so that clips which
+// keep failing aren't chosen again.
+func (vmw *VideoWindowContext) mediaFailed(location string, err error) {
+	vmw.Quarantine.Add(location, err)
+
+	if location == vmw.current {
+		vmw.current = ""
+	}
+}
+
+// maxMissingMedia is how many vanished files we will skip over in a row
+// before just trying to play whatever comes next.
+const maxMissingMedia = 10
+
+// nextAvailable returns the next item from the selector, passing over local
+// files that have been deleted since the library was last updated.
+func (vmw *VideoWindowContext) nextAvailable() (MediaItem, error) {
+	for attempt := 0; ; attempt++ {
+		item, err := vmw.selector.Next()
+		if err != nil || isMediaURL(item.Location) || attempt >= maxMissingMedia {
+			return item, err
+		}
+
+		if _, err := os.Stat(item.Location); err == nil {
+			return item, nil
+		}
+
+		log.Printf("%s: %v has gone, choosing again", vmw.Identifier, item.Location)
+		if vmw.Index != nil {
+			vmw.Index.Update(item.Location)
+		}
+	}
+}
+
+// finishCurrent records how long the current item was shown for, if there is
+// one.
+func (vmw *VideoWindowContext) finishCurrent() {
+	if len(vmw.current) == 0 {
+		return
+	}
+
+	vmw.History.Finished(vmw.current, time.Since(vmw.currentStarted))
+	vmw.current = ""
 }
 
 func (vmw *VideoWindowContext) Init() {
@@ -56,6 +158,17 @@ func (vmw *VideoWindowContext) Init() {
 	var videoWidget *VlcVideoWidget
 	var err error
 
+	// Each window deals from its own decks, seeded from the global source so
+	// that monitors don't play in lockstep.
+	vmw.selector = NewMediaSelector(vmw.Sources, vmw.MediaExtensions, vmw.SelectionMode, vmw.History, rand.Int63())
+	vmw.selector.Index = vmw.Index
+	vmw.selector.Quarantine = vmw.Quarantine
+	if aspect := vmw.targetAspect(); aspect > 0 {
+		vmw.selector.AspectMatching = vmw.AspectMatching
+		vmw.selector.TargetAspect = aspect
+		vmw.selector.Geometry = vmw.Index.Geometry
+	}
+
 	if vmw.Parent == win.HWND(0) {
 		declarative.MainWindow{
 			AssignTo: &vmw.mainWindow,
@@ -75,9 +188,9 @@ func (vmw *VideoWindowContext) Init() {
 			func() {
 				vmw.mainWindow.Close()
 			},
-			func() string {
-				return vmw.getMedia()
-			},
+			vmw.getMedia,
+			vmw.mediaStarted,
+			vmw.mediaFailed,
 			vmw.mainWindow.Synchronize)
 		if err != nil {
 			log.Panic(err)
@@ -86,23 +199,108 @@ func (vmw *VideoWindowContext) Init() {
 		videoWidget, err = NewPreviewVlcVideoWidget(
 			vmw.Parent,
 			vmw.getMedia,
-			func(func()) {})
+			vmw.mediaStarted,
+			vmw.mediaFailed,
+			vmw.Dispatcher.Dispatch)
 		if err != nil {
 			log.Panic(err)
 		}
 	}
 
 	vmw.videoWidget = videoWidget
+	vmw.videoWidget.FallbackClip = FallbackClip
+	vmw.videoWidget.Crossfade = CrossfadeDuration
+
+	if vmw.Mirror != nil {
+		vmw.videoWidget.ClipFinished = func() {
+			vmw.Mirror.Finished(vmw.MirrorMember)
+		}
+		vmw.Mirror.Join(vmw.MirrorMember, vmw.videoWidget.Advance)
+	}
 
 	if vmw.mainWindow != nil {
 		vmw.mainWindow.SetFullscreen(true)
 	}
 
+	if vmw.Span != nil {
+		vmw.spanDesktop()
+	}
+
 	vmw.videoWidget.SetupVlcPlayer()
+
+	if vmw.Span != nil && vmw.Span.hasBezels() {
+		vmw.showSpanViews()
+	}
+}
+
+// targetAspect is the shape of the screen clips are shown on, or 0 if it
+// isn't known.
+func (vmw *VideoWindowContext) targetAspect() float64 {
+	if vmw.Span != nil {
+		return float64(vmw.Span.Canvas.X) / float64(vmw.Span.Canvas.Y)
+	}
+	if vmw.Bounds.Width > 0 && vmw.Bounds.Height > 0 {
+		return float64(vmw.Bounds.Width) / float64(vmw.Bounds.Height)
+	}
+	return 0
+}
+
+// spanDesktop stretches the window, which SetFullscreen fits to a single
+// monitor, across all of them, and sizes the picture to suit.
+func (vmw *VideoWindowContext) spanDesktop() {
+	bounds := vmw.Span.Bounds
+	if !win.SetWindowPos(vmw.mainWindow.Handle(), win.HWND_TOP,
+		int32(bounds.Min.X), int32(bounds.Min.Y), int32(bounds.Dx()), int32(bounds.Dy()),
+		win.SWP_FRAMECHANGED|win.SWP_NOOWNERZORDER) {
+		log.Printf("%s: unable to span the desktop: %d", vmw.Identifier, win.GetLastError())
+	}
+
+	switch SpanFitting {
+	case SpanFill:
+		vmw.videoWidget.CropGeometry = vmw.Span.canvasAspect()
+	case SpanStretch:
+		vmw.videoWidget.AspectRatio = vmw.Span.canvasAspect()
+	}
+}
+
+// showSpanViews puts a view on each monitor, showing its part of the video
+// in front of the video window.
+func (vmw *VideoWindowContext) showSpanViews() {
+	for i, view := range vmw.Span.Views {
+		sv, err := newSpanView(vmw.Span.Monitors[i], vmw.mainWindow.Handle(), vmw.Span.canvasToWindow(view), func() {
+			vmw.mainWindow.Close()
+		})
+		if err != nil {
+			log.Panic(err)
+		}
+		vmw.views = append(vmw.views, sv)
+	}
+}
+
+// syncMirrors keeps mirrored windows playing in step with the first that is
+// playing anything, while they are showing the same clip, until the returned
+// function is called.
+func syncMirrors(windows []*VideoWindowContext) (stop func()) {
+	sources := make([]syncSource, len(windows))
+	for i, vmw := range windows {
+		sources[i] = vmw.videoWidget
+	}
+
+	ps := newPlaybackSync(func() []syncMember {
+		return sameClipMembers(sources)
+	})
+	ps.Threshold = SyncThreshold
+	return ps.Run(playbackSyncInterval, windows[0].mainWindow.Synchronize)
 }
 
 func (vmw *VideoWindowContext) Deinit() {
+	for _, view := range vmw.views {
+		view.Close()
+	}
+	vmw.views = nil
+
 	vmw.videoWidget.Deinit()
+	vmw.finishCurrent()
 }
 
 type Monitor struct {
@@ -177,113 +375,156 @@ func initRand() {
 	rand.Seed(int64(binary.LittleEndian.Uint64(b[:])))
 }
 
-func showConfigureWindow() {
-	var mw *walk.MainWindow
-	var mediaPathTextEdit *walk.TextEdit
-
-	win.CoInitializeEx(nil, win.COINIT_APARTMENTTHREADED)
-
-	declarative.MainWindow{
-		AssignTo: &mw,
-		Title:    "Configure Video Screensaver",
-		MinSize:  declarative.Size{Width: 300, Height: 150},
-		Size:     declarative.Size{Width: 400, Height: 150},
-		Layout:   declarative.VBox{},
-		// Font:     Font{Family: "Arial"},
-		Children: []declarative.Widget{
-			declarative.Label{
-				Text: "Use videos from:",
-			},
-			declarative.Composite{
-				Layout: declarative.HBox{MarginsZero: true},
-				Children: []declarative.Widget{
-					declarative.HSpacer{},
-					declarative.Label{
-						Text: MediaPath,
-					},
-					declarative.HSpacer{},
-					declarative.PushButton{
-						Text: "Browse",
-						OnClicked: func() {
-							log.Print("Button clicked")
-							dlg := new(walk.FileDialog)
-
-							dlg.Title = "Select a media path"
-							if ok, err := dlg.ShowBrowseFolder(mw); err != nil {
-								log.Fatalf("err is %v", err)
-								return
-							} else if !ok {
-								log.Print("not ok - user cancelled")
-								return
-							}
-
-							log.Printf("User selected media path %v", dlg.FilePath)
-
-							setMediaPath(dlg.FilePath)
-							mediaPathTextEdit.SetText(dlg.FilePath)
-						},
-					},
-				},
-			},
-			declarative.VSpacer{},
-			declarative.VSeparator{},
-			declarative.Composite{
-				Layout: declarative.HBox{MarginsZero: true},
-				Children: []declarative.Widget{
-					declarative.HSpacer{},
-					declarative.PushButton{
-						Text: "Ok",
-						OnClicked: func() {
-							mw.Close()
-						},
-					},
-				},
-			},
-		},
-	}.Run()
-}
+// wmRunQueued is posted to a preview's thread when it has work queued.
+const wmRunQueued = win.WM_APP + 1
+
+var postThreadMessage = windows.NewLazySystemDLL("user32.dll").NewProc("PostThreadMessageW")
 
 func runScreenSaver(parent win.HWND) {
 	win.CoInitializeEx(nil, win.COINIT_MULTITHREADED)
 
 	monitorRects := listMonitors()
 
+	var span *spanLayout
+	if parent == win.HWND(0) && Display == SpanDisplay {
+		var monitors []spanMonitor
+		for _, mon := range monitorRects {
+			monitors = append(monitors, spanMonitor{
+				Name: mon.Name,
+				Rect: image.Rect(int(mon.Rect.Left), int(mon.Rect.Top), int(mon.Rect.Right), int(mon.Rect.Bottom)),
+			})
+		}
+		layout := newSpanLayout(monitors, SpanBezelGaps)
+		span = &layout
+		log.Printf("Spanning %v, with a %v canvas", span.Bounds, span.Canvas)
+	}
+
 	newpath := os.Getenv("PATH") + ";" + InstallPath
 	log.Print("Setting PATH to: ", newpath)
 	os.Setenv("PATH", newpath)
 
-	err := vlc.Init("--no-audio") // , "--verbose=2"
+	vlc.LibrarySearchPath = []string{InstallPath}
+
+	args := []string{"--no-audio"}
+	if span != nil && span.hasBezels() {
+		// The video window is squeezed to fit the desktop, which the views
+		// on each monitor stretch back out again.
+		args = append(args, "--monitor-par="+span.pixelAspect())
+	}
+
+	err := vlc.Init(args...)
 	if err != nil {
 		log.Panic(err)
 	}
+	log.Printf("Using libvlc %v, compiled with %v", vlc.RuntimeVersion(), vlc.Compiler())
+
+	// We have no console for libvlc to log to, so take its messages into our
+	// own log.
+	if err := vlc.SetLogger(VlcLogFilter, logVlcMessage); err != nil {
+		log.Printf("Unable to log libvlc's messages: %v", err)
+	}
 
 	// log.Print(vlc.AudioOutputList())
 
+	history := LoadPlayHistory(InstallPath + "\\history.json")
+	quarantine := LoadQuarantine(InstallPath + "\\quarantine.json")
+
+	// The first clips are listed from the index as it was left last time,
+	// while it is brought up to date in the background.
+	index := LoadMediaIndex(InstallPath + "\\index.json")
+	index.Probe = probeMedia
+	index.RefreshInBackground(allMediaSources(), MediaExtensions)
+	index.Watch(allMediaSources(), MediaExtensions, LibraryPollInterval)
+
 	var windows []*VideoWindowContext
+	var stopSync func()
+
+	// A preview runs its own message loop, which is woken to do work queued
+	// for it.
+	var preview *vlc.QueueDispatcher
+	if parent != win.HWND(0) {
+		thread := win.GetCurrentThreadId()
+		preview = vlc.NewQueueDispatcher(func() {
+			postThreadMessage.Call(uintptr(thread), wmRunQueued, 0, 0)
+		})
+	}
 
-	if parent == win.HWND(0) {
-		for _, mon := range monitorRects {
+	if span != nil {
+		var videoWindow *VideoWindowContext = &VideoWindowContext{
+			Sources:         MediaSources,
+			MediaExtensions: MediaExtensions,
+			SelectionMode:   MediaSelectionMode,
+			AspectMatching:  MediaAspectMatching,
+			History:         history,
+			Index:           index,
+			Quarantine:      quarantine,
+			Bounds: declarative.Rectangle{
+				X:      span.Bounds.Min.X,
+				Y:      span.Bounds.Min.Y,
+				Width:  span.Bounds.Dx(),
+				Height: span.Bounds.Dy(),
+			},
+			Identifier: "Span",
+			Span:       span,
+		}
+		videoWindow.Init()
+
+		windows = append(windows, videoWindow)
+	} else if parent == win.HWND(0) {
+		// Mirrored monitors all play the clips chosen by the first.
+		var mirror *mirrorGroup
+		if Display == MirrorDisplay {
+			mirror = newMirrorGroup(len(monitorRects), func() (MediaItem, error) {
+				return windows[0].nextAvailable()
+			})
+			mirror.Timeout = MirrorTimeout
+		}
+
+		for i, mon := range monitorRects {
 			rect := mon.Rect
+			sources, selectionMode := MonitorSources.Resolve(mon.Name, MediaSources, MediaSelectionMode)
+			if mirror != nil {
+				sources, selectionMode = MediaSources, MediaSelectionMode
+			}
 			var videoWindow *VideoWindowContext = &VideoWindowContext{
-				MediaPath: MediaPath,
+				Sources:         sources,
+				MediaExtensions: MediaExtensions,
+				SelectionMode:   selectionMode,
+				AspectMatching:  MediaAspectMatching,
+				History:         history,
+				Index:           index,
+				Quarantine:      quarantine,
 				Bounds: declarative.Rectangle{
 					X:      int(rect.Left),
 					Y:      int(rect.Top),
 					Width:  int(rect.Right - rect.Left),
 					Height: int(rect.Bottom - rect.Top),
 				},
-				Identifier: mon.Name,
+				Identifier:   mon.Name,
+				Mirror:       mirror,
+				MirrorMember: i,
 			}
+			// The first window is listed before it starts, as it chooses
+			// clips for the mirror group.
+			windows = append(windows, videoWindow)
 			videoWindow.Init()
+		}
 
-			windows = append(windows, videoWindow)
+		if mirror != nil && len(windows) > 1 && SyncThreshold > 0 {
+			stopSync = syncMirrors(windows)
 		}
 	} else {
 		// rect := mon.Rect
 		var videoWindow *VideoWindowContext = &VideoWindowContext{
-			MediaPath:  MediaPath,
-			Identifier: "Preview",
-			Parent:     parent,
+			Sources:         MediaSources,
+			MediaExtensions: MediaExtensions,
+			SelectionMode:   MediaSelectionMode,
+			History:         history,
+			Index:           index,
+			Quarantine:      quarantine,
+			Identifier:      "Preview",
+			Parent:          parent,
+			Dispatcher:      preview,
 		}
 		videoWindow.Init()
 
@@ -311,16 +552,30 @@ func runScreenSaver(parent win.HWND) {
 				// break // return -1
 			}
 
+			if msg.HWnd == 0 && msg.Message == wmRunQueued {
+				preview.RunPending()
+				continue
+			}
+
 			win.TranslateMessage(msg)
 			win.DispatchMessage(msg)
 		}
 	}
 
+	if stopSync != nil {
+		stopSync()
+	}
+
 	for _, vmw := range windows {
 		vmw.Deinit()
 	}
 
-	vlc.Release()
+	// Background probing and watching use libvlc, so must finish first.
+	index.Close()
+
+	if err := vlc.Release(); err != nil {
+		log.Printf("Unable to release libvlc: %v", err)
+	}
 }
 
 type CommandType int
@@ -380,6 +635,7 @@ func parseCommandLineArgs(args []string) Command {
 func init() {
 	walk.AppendToWalkInit(func() {
 		walk.MustRegisterWindowClass(VlcVideoWidgetWindowClass)
+		walk.MustRegisterWindowClass(SpanViewWidgetWindowClass)
 	})
 }
 
@@ -399,14 +655,117 @@ func loadRegistryEntries() {
 		MediaPath, _ = os.Getwd()
 	}
 	log.Printf("Using media path of %v", MediaPath)
+
+	// MediaSources supersedes MediaPath, which is kept for configurations
+	// saved before there could be more than one source.
+	sources, err := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "MediaSources")
+	if err == nil {
+		MediaSources, err = parseMediaSources(sources)
+		if err != nil {
+			log.Printf("Ignoring invalid media sources: %v", err)
+		}
+	}
+	if len(MediaSources) == 0 {
+		MediaSources = []MediaSource{newMediaSource(MediaPath)}
+	}
+	for _, source := range MediaSources {
+		log.Printf("Using media source %v", source)
+	}
+
+	extensions, err := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "MediaExtensions")
+	if err == nil {
+		MediaExtensions = parseMediaExtensions(extensions)
+	}
+	if len(MediaExtensions) == 0 {
+		MediaExtensions = DefaultMediaExtensions
+	}
+	log.Printf("Using media extensions %v", strings.Join(MediaExtensions, ";"))
+
+	selectionMode, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "SelectionMode")
+	MediaSelectionMode = parseSelectionMode(selectionMode)
+	log.Printf("Using selection mode %v", MediaSelectionMode)
+
+	monitorSources, err := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "MonitorSources")
+	if err == nil {
+		MonitorSources, err = parseMonitorConfigs(monitorSources)
+		if err != nil {
+			log.Printf("Ignoring invalid per-monitor sources: %v", err)
+		}
+	}
+	for name, config := range MonitorSources {
+		log.Printf("Using for monitor %v: sources %v, selection mode %q", name, config.Sources, config.SelectionMode)
+	}
+
+	aspectMatch, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "AspectMatch")
+	aspectTolerance, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "AspectTolerance")
+	aspectFallback, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "AspectFallback")
+	MediaAspectMatching = parseAspectMatching(aspectMatch, aspectTolerance, aspectFallback)
+	log.Printf("Using aspect matching %+v", MediaAspectMatching)
+
+	pollInterval, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "PollInterval")
+	LibraryPollInterval = parsePollInterval(pollInterval)
+	log.Printf("Using poll interval %v", LibraryPollInterval)
+
+	FallbackClip, _ = common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "FallbackClip")
+	if len(FallbackClip) > 0 {
+		log.Printf("Using fallback clip %v", FallbackClip)
+	}
+
+	display, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "DisplayMode")
+	Display = parseDisplayMode(display)
+	log.Printf("Using display mode %v", Display)
+
+	if Display == SpanDisplay {
+		fit, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "SpanFit")
+		SpanFitting = parseSpanFit(fit)
+
+		bezels, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "SpanBezels")
+		SpanBezelGaps, err = parseSpanBezels(bezels)
+		if err != nil {
+			log.Printf("Invalid span bezels, ignoring them: %v", err)
+		}
+		log.Printf("Spanning monitors with fit %v and bezels %+v", SpanFitting, SpanBezelGaps)
+	}
+
+	if Display == MirrorDisplay {
+		mirrorTimeout, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "MirrorTimeout")
+		MirrorTimeout = parseMirrorTimeout(mirrorTimeout)
+		log.Printf("Mirroring monitors, waiting up to %v for them all to finish a clip", MirrorTimeout)
+
+		syncThreshold, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "SyncThreshold")
+		SyncThreshold = parseSyncThreshold(syncThreshold)
+		log.Printf("Keeping mirrored monitors within %v of each other", SyncThreshold)
+	}
+
+	crossfade, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "Crossfade")
+	CrossfadeDuration = parseCrossfade(crossfade)
+	if CrossfadeDuration > 0 {
+		log.Printf("Crossfading over %v", CrossfadeDuration)
+	}
+
+	vlcLogLevel, _ := common.RegistryLoadString("Software\\sammydre\\golang-video-screensaver", "VlcLogLevel")
+	VlcLogFilter = parseVlcLogFilter(vlcLogLevel)
+}
+
+// allMediaSources returns the default sources along with those configured for
+// particular monitors.
+func allMediaSources() []MediaSource {
+	var ret = append([]MediaSource(nil), MediaSources...)
+	for _, config := range MonitorSources {
+		ret = append(ret, config.Sources...)
+	}
+	return ret
 }
 
-func setMediaPath(path string) {
-	common.RegistrySaveString(
+func setMediaSources(sources []MediaSource) {
+	err := common.RegistrySaveString(
 		"Software\\sammydre\\golang-video-screensaver",
-		"MediaPath",
-		path)
-	MediaPath = path
+		"MediaSources",
+		formatMediaSources(sources))
+	if err != nil {
+		log.Printf("Error saving media sources: %v", err)
+	}
+	MediaSources = sources
 }
 
 func setupLogging() {
@@ -419,8 +778,8 @@ func setupLogging() {
 
 	cwd, _ := os.Getwd()
 
-	log.Printf("Logging to file initialised. InstallPath %v MediaPath %v Cwd %v Args %v",
-		InstallPath, MediaPath, cwd, os.Args)
+	log.Printf("Logging to file initialised. InstallPath %v MediaSources %v Cwd %v Args %v",
+		InstallPath, MediaSources, cwd, os.Args)
 }
 
 func main() {
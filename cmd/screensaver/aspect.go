@@ -0,0 +1,150 @@
+package main
+
+import (
+	"log"
+	"math"
+	"strconv"
+)
+
+// AspectMatchMode controls whether clips are matched to the shape of the
+// monitor they are shown on.
+type AspectMatchMode string
+
+const (
+	// AspectMatchOff ignores the shape of clips entirely.
+	AspectMatchOff AspectMatchMode = "off"
+	// AspectMatchPrefer plays matching clips from the chosen source if there
+	// are any, otherwise falls back.
+	AspectMatchPrefer AspectMatchMode = "prefer"
+	// AspectMatchRequire skips sources with no matching clips, and only falls
+	// back if no source has any.
+	AspectMatchRequire AspectMatchMode = "require"
+)
+
+// AspectFallback says what to play when no clip matches.
+type AspectFallback string
+
+const (
+	// AspectFallbackAny plays anything, as if matching were off.
+	AspectFallbackAny AspectFallback = "any"
+	// AspectFallbackClosest plays the clip whose shape is closest.
+	AspectFallbackClosest AspectFallback = "closest"
+)
+
+// DefaultAspectTolerance accepts 16:10 clips on a 16:9 monitor and vice
+// versa, but not 4:3.
+const DefaultAspectTolerance = 0.15
+
+// AspectMatching is the configuration for matching clips to monitors.
+type AspectMatching struct {
+	Mode      AspectMatchMode
+	Tolerance float64
+	Fallback  AspectFallback
+}
+
+func parseAspectMatching(mode, tolerance, fallback string) AspectMatching {
+	var ret = AspectMatching{
+		Mode:      AspectMatchOff,
+		Tolerance: DefaultAspectTolerance,
+		Fallback:  AspectFallbackAny,
+	}
+
+	switch AspectMatchMode(mode) {
+	case AspectMatchPrefer, AspectMatchRequire:
+		ret.Mode = AspectMatchMode(mode)
+	case AspectMatchOff, "":
+	default:
+		log.Printf("Unknown aspect match mode %q, using %q", mode, ret.Mode)
+	}
+
+	if len(tolerance) > 0 {
+		if value, err := strconv.ParseFloat(tolerance, 64); err == nil && value >= 0 {
+			ret.Tolerance = value
+		} else {
+			log.Printf("Invalid aspect tolerance %q, using %v", tolerance, ret.Tolerance)
+		}
+	}
+
+	switch AspectFallback(fallback) {
+	case AspectFallbackClosest:
+		ret.Fallback = AspectFallbackClosest
+	case AspectFallbackAny, "":
+	default:
+		log.Printf("Unknown aspect fallback %q, using %q", fallback, ret.Fallback)
+	}
+
+	return ret
+}
+
+// VideoGeometry is the display size of a clip.
+type VideoGeometry struct {
+	Width  int
+	Height int
+}
+
+func (vg VideoGeometry) Aspect() float64 {
+	if vg.Height == 0 {
+		return 0
+	}
+	return float64(vg.Width) / float64(vg.Height)
+}
+
+// aspectDistance is how far apart two aspect ratios are, as a proportion: 0
+// if they are the same, 0.5 if one is half as wide again as the other.
+func aspectDistance(a, b float64) float64 {
+	if a <= 0 || b <= 0 {
+		return math.Inf(1)
+	}
+	ratio := a / b
+	if ratio < 1 {
+		ratio = 1 / ratio
+	}
+	return ratio - 1
+}
+
+// GeometryFunc returns the geometry of the clip at location, if it is known.
+type GeometryFunc func(location string) (VideoGeometry, bool)
+
+// displayGeometry returns the shape item is shown at, allowing for its
+// sidecar turning it on its side.
+func displayGeometry(item MediaItem, geometry GeometryFunc) (VideoGeometry, bool) {
+	g, ok := geometry(item.Location)
+	if ok && (item.Playback.Rotation == 90 || item.Playback.Rotation == 270) {
+		g.Width, g.Height = g.Height, g.Width
+	}
+	return g, ok
+}
+
+// filter returns those items whose aspect ratio is within tolerance of
+// target. Items of unknown shape never match.
+func (am AspectMatching) filter(items []MediaItem, target float64, geometry GeometryFunc) []MediaItem {
+	var ret []MediaItem
+
+	for _, item := range items {
+		g, ok := displayGeometry(item, geometry)
+		if ok && aspectDistance(g.Aspect(), target) <= am.Tolerance {
+			ret = append(ret, item)
+		}
+	}
+
+	return ret
+}
+
+// closestAspect returns the item whose aspect ratio is nearest to target,
+// ignoring items of unknown shape.
+func closestAspect(items []MediaItem, target float64, geometry GeometryFunc) (MediaItem, bool) {
+	var best MediaItem
+	var bestDistance = math.Inf(1)
+
+	for _, item := range items {
+		g, ok := displayGeometry(item, geometry)
+		if !ok {
+			continue
+		}
+		if distance := aspectDistance(g.Aspect(), target); distance < bestDistance {
+			best, bestDistance = item, distance
+		}
+	}
+
+	return best, !math.IsInf(bestDistance, 1)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/lxn/walk"
+	"github.com/lxn/walk/declarative"
+	"github.com/lxn/win"
+)
+
+func showConfigureWindow() {
+	var mw *walk.MainWindow
+	var sourceList *walk.ListBox
+	var weightEdit *walk.NumberEdit
+	var enabledCheck *walk.CheckBox
+	var recursiveCheck *walk.CheckBox
+	var inOrderCheck *walk.CheckBox
+	var randomStartCheck *walk.CheckBox
+	var maxMinutesEdit *walk.NumberEdit
+	var urlsEdit *walk.TextEdit
+	var removeButton *walk.PushButton
+
+	// Edits are made to a copy, and only saved when the user clicks Ok.
+	sources := append([]MediaSource(nil), MediaSources...)
+	current := -1
+
+	// Set while we are filling in the controls ourselves, so their change
+	// handlers don't write the values straight back.
+	updating := false
+
+	showCurrent := func() {
+		updating = true
+		defer func() { updating = false }()
+
+		valid := current >= 0 && current < len(sources)
+		weightEdit.SetEnabled(valid)
+		enabledCheck.SetEnabled(valid)
+		removeButton.SetEnabled(valid)
+		randomStartCheck.SetEnabled(valid)
+		maxMinutesEdit.SetEnabled(valid)
+		recursiveCheck.SetEnabled(valid && sources[current].Type == DirectorySource)
+		inOrderCheck.SetEnabled(valid && sources[current].Type != DirectorySource)
+		urlsEdit.SetEnabled(valid && sources[current].Type == URLListSource)
+
+		if !valid {
+			weightEdit.SetValue(0)
+			enabledCheck.SetChecked(false)
+			recursiveCheck.SetChecked(false)
+			inOrderCheck.SetChecked(false)
+			randomStartCheck.SetChecked(false)
+			maxMinutesEdit.SetValue(0)
+			urlsEdit.SetText("")
+			return
+		}
+
+		source := sources[current]
+		weightEdit.SetValue(source.Weight)
+		enabledCheck.SetChecked(source.Enabled)
+		recursiveCheck.SetChecked(source.Recursive)
+		inOrderCheck.SetChecked(source.InOrder)
+		randomStartCheck.SetChecked(source.RandomStart)
+		maxMinutesEdit.SetValue(source.MaxMinutes)
+		urlsEdit.SetText(strings.Join(source.URLs, "\r\n"))
+	}
+
+	refreshList := func() {
+		updating = true
+		defer func() { updating = false }()
+
+		var model []string
+		for _, source := range sources {
+			model = append(model, source.String())
+		}
+
+		sourceList.SetModel(model)
+		sourceList.SetCurrentIndex(current)
+	}
+
+	addSource := func(source MediaSource) {
+		sources = append(sources, source)
+		current = len(sources) - 1
+		refreshList()
+		showCurrent()
+	}
+
+	// editCurrent applies a change from one of the controls to the selected
+	// source.
+	editCurrent := func(edit func(source *MediaSource)) {
+		if updating || current < 0 || current >= len(sources) {
+			return
+		}
+
+		edit(&sources[current])
+		refreshList()
+	}
+
+	win.CoInitializeEx(nil, win.COINIT_APARTMENTTHREADED)
+
+	err := declarative.MainWindow{
+		AssignTo: &mw,
+		Title:    "Configure Video Screensaver",
+		MinSize:  declarative.Size{Width: 400, Height: 350},
+		Size:     declarative.Size{Width: 550, Height: 450},
+		Layout:   declarative.VBox{},
+		// Font:     Font{Family: "Arial"},
+		Children: []declarative.Widget{
+			declarative.Label{
+				Text: "Use videos from:",
+			},
+			declarative.ListBox{
+				AssignTo: &sourceList,
+				OnCurrentIndexChanged: func() {
+					if updating {
+						return
+					}
+					current = sourceList.CurrentIndex()
+					showCurrent()
+				},
+			},
+			declarative.Composite{
+				Layout: declarative.HBox{MarginsZero: true},
+				Children: []declarative.Widget{
+					declarative.PushButton{
+						Text: "Add folder",
+						OnClicked: func() {
+							dlg := new(walk.FileDialog)
+
+							dlg.Title = "Select a media path"
+							if ok, err := dlg.ShowBrowseFolder(mw); err != nil {
+								log.Fatalf("err is %v", err)
+								return
+							} else if !ok {
+								log.Print("not ok - user cancelled")
+								return
+							}
+
+							log.Printf("User selected media path %v", dlg.FilePath)
+							addSource(newMediaSource(dlg.FilePath))
+						},
+					},
+					declarative.PushButton{
+						Text: "Add playlist",
+						OnClicked: func() {
+							dlg := new(walk.FileDialog)
+
+							dlg.Title = "Select a playlist"
+							dlg.Filter = "Playlists (*.m3u;*.m3u8;*.pls;*.xspf)|*.m3u;*.m3u8;*.pls;*.xspf"
+							if ok, err := dlg.ShowOpen(mw); err != nil {
+								log.Fatalf("err is %v", err)
+								return
+							} else if !ok {
+								log.Print("not ok - user cancelled")
+								return
+							}
+
+							log.Printf("User selected playlist %v", dlg.FilePath)
+							addSource(newMediaSource(dlg.FilePath))
+						},
+					},
+					declarative.PushButton{
+						Text: "Add URL list",
+						OnClicked: func() {
+							addSource(MediaSource{
+								Type:    URLListSource,
+								Weight:  DefaultSourceWeight,
+								Enabled: true,
+							})
+						},
+					},
+					declarative.HSpacer{},
+					declarative.PushButton{
+						AssignTo: &removeButton,
+						Text:     "Remove",
+						OnClicked: func() {
+							if current < 0 || current >= len(sources) {
+								return
+							}
+							sources = append(sources[:current], sources[current+1:]...)
+							if current >= len(sources) {
+								current = len(sources) - 1
+							}
+							refreshList()
+							showCurrent()
+						},
+					},
+				},
+			},
+			declarative.Composite{
+				Layout: declarative.HBox{MarginsZero: true},
+				Children: []declarative.Widget{
+					declarative.Label{
+						Text: "Weight:",
+					},
+					declarative.NumberEdit{
+						AssignTo: &weightEdit,
+						Decimals: 1,
+						MinValue: 0,
+						MaxValue: 1000,
+						OnValueChanged: func() {
+							editCurrent(func(source *MediaSource) {
+								source.Weight = weightEdit.Value()
+							})
+						},
+					},
+					declarative.CheckBox{
+						AssignTo: &enabledCheck,
+						Text:     "Enabled",
+						OnCheckedChanged: func() {
+							editCurrent(func(source *MediaSource) {
+								source.Enabled = enabledCheck.Checked()
+							})
+						},
+					},
+					declarative.CheckBox{
+						AssignTo: &recursiveCheck,
+						Text:     "Include subfolders",
+						OnCheckedChanged: func() {
+							editCurrent(func(source *MediaSource) {
+								source.Recursive = recursiveCheck.Checked()
+							})
+						},
+					},
+					declarative.CheckBox{
+						AssignTo: &inOrderCheck,
+						Text:     "Play in order",
+						OnCheckedChanged: func() {
+							editCurrent(func(source *MediaSource) {
+								source.InOrder = inOrderCheck.Checked()
+							})
+						},
+					},
+					declarative.HSpacer{},
+				},
+			},
+			declarative.Composite{
+				Layout: declarative.HBox{MarginsZero: true},
+				Children: []declarative.Widget{
+					declarative.CheckBox{
+						AssignTo: &randomStartCheck,
+						Text:     "Start at a random point",
+						OnCheckedChanged: func() {
+							editCurrent(func(source *MediaSource) {
+								source.RandomStart = randomStartCheck.Checked()
+							})
+						},
+					},
+					declarative.Label{
+						Text: "Switch after at most (minutes, 0 for no limit):",
+					},
+					declarative.NumberEdit{
+						AssignTo: &maxMinutesEdit,
+						Decimals: 1,
+						MinValue: 0,
+						MaxValue: 1440,
+						OnValueChanged: func() {
+							editCurrent(func(source *MediaSource) {
+								source.MaxMinutes = maxMinutesEdit.Value()
+							})
+						},
+					},
+					declarative.HSpacer{},
+				},
+			},
+			declarative.Label{
+				Text: "URLs, one per line:",
+			},
+			declarative.TextEdit{
+				AssignTo: &urlsEdit,
+				VScroll:  true,
+				OnTextChanged: func() {
+					editCurrent(func(source *MediaSource) {
+						source.URLs = nil
+						for _, line := range strings.Split(urlsEdit.Text(), "\n") {
+							if line = strings.TrimSpace(line); len(line) > 0 {
+								source.URLs = append(source.URLs, line)
+							}
+						}
+					})
+				},
+			},
+			declarative.VSeparator{},
+			declarative.Composite{
+				Layout: declarative.HBox{MarginsZero: true},
+				Children: []declarative.Widget{
+					declarative.HSpacer{},
+					declarative.PushButton{
+						Text: "Ok",
+						OnClicked: func() {
+							setMediaSources(sources)
+							mw.Close()
+						},
+					},
+					declarative.PushButton{
+						Text: "Cancel",
+						OnClicked: func() {
+							mw.Close()
+						},
+					},
+				},
+			},
+		},
+	}.Create()
+
+	if err != nil {
+		log.Panicf("Failed to create configure window: %v", err)
+	}
+
+	if len(sources) > 0 {
+		current = 0
+	}
+	refreshList()
+	showCurrent()
+
+	mw.Run()
+}
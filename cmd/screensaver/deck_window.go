@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/lxn/win"
+	vlc "github.com/sammydre/golang-video-screensaver/vlcwrap"
+)
+
+// childDeckWindow is a deck's window: a child of the video widget's.
+type childDeckWindow struct {
+	hwnd win.HWND
+}
+
+// The "win" module doesn't wrap SetLayeredWindowAttributes either.
+var setLayeredWindowAttributes = windows.NewLazySystemDLL("user32.dll").NewProc("SetLayeredWindowAttributes")
+
+const lwaAlpha = 0x2
+
+// newVideoDeck creates a player rendering into a new child window of parent,
+// covering all of it. Layered windows can be faded in, for crossfades.
+func newVideoDeck(parent win.HWND, layered bool) (*videoDeck, error) {
+	var rect win.RECT
+	win.GetClientRect(parent, &rect)
+
+	var exStyle uint32
+	if layered {
+		exStyle = win.WS_EX_LAYERED
+	}
+
+	// Static controls let mouse messages through to the widget underneath,
+	// which needs them to notice the user is back.
+	className, _ := syscall.UTF16PtrFromString("STATIC")
+	hwnd := win.CreateWindowEx(
+		exStyle,
+		className,
+		nil,
+		win.WS_CHILD|win.SS_BLACKRECT,
+		0, 0, rect.Right-rect.Left, rect.Bottom-rect.Top,
+		parent,
+		0,
+		win.GetModuleHandle(nil),
+		nil)
+	if hwnd == 0 {
+		return nil, syscall.Errno(win.GetLastError())
+	}
+
+	window := &childDeckWindow{hwnd: hwnd}
+	if layered {
+		window.setAlpha(255)
+	}
+
+	player, err := vlc.NewPlayer()
+	if err != nil {
+		window.destroy()
+		return nil, err
+	}
+
+	deck := &videoDeck{player: player, window: window}
+
+	if err = player.SetHWND(uintptr(hwnd)); err != nil {
+		deck.release()
+		return nil, err
+	}
+
+	if err = player.SetKeyInput(false); err != nil {
+		deck.release()
+		return nil, err
+	}
+
+	if err = player.SetMouseInput(false); err != nil {
+		deck.release()
+		return nil, err
+	}
+
+	if err = player.SetAudioOutput("adummy"); err != nil {
+		log.Print(err)
+	}
+
+	if err = player.SetMute(true); err != nil {
+		deck.release()
+		return nil, err
+	}
+
+	return deck, nil
+}
+
+func (cdw *childDeckWindow) show() {
+	win.SetWindowPos(cdw.hwnd, win.HWND_TOP, 0, 0, 0, 0,
+		win.SWP_NOMOVE|win.SWP_NOSIZE|win.SWP_NOACTIVATE|win.SWP_SHOWWINDOW)
+}
+
+func (cdw *childDeckWindow) hide() {
+	win.ShowWindow(cdw.hwnd, win.SW_HIDE)
+}
+
+func (cdw *childDeckWindow) setAlpha(alpha byte) {
+	ret, _, err := setLayeredWindowAttributes.Call(uintptr(cdw.hwnd), 0, uintptr(alpha), lwaAlpha)
+	if ret == 0 {
+		log.Printf("SetLayeredWindowAttributes: %v", err)
+	}
+}
+
+func (cdw *childDeckWindow) resize(width, height int32) {
+	win.MoveWindow(cdw.hwnd, 0, 0, width, height, true)
+}
+
+func (cdw *childDeckWindow) destroy() {
+	win.DestroyWindow(cdw.hwnd)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PlayRecord is what we remember about a single media item.
+type PlayRecord struct {
+	Count         int           `json:"count"`
+	LastPlayed    time.Time     `json:"lastPlayed"`
+	LastDuration  time.Duration `json:"lastDuration"`
+	TotalDuration time.Duration `json:"totalDuration"`
+}
+
+// PlayHistory is a persisted record of what has been played, shared between
+// all windows. Every change is written straight back to disk, as the
+// screensaver can be killed at any moment.
+type PlayHistory struct {
+	sync.Mutex
+
+	path    string
+	records map[string]*PlayRecord
+}
+
+// LoadPlayHistory reads the history stored at path. A missing or unreadable
+// file is not an error: we log it and start again with an empty history,
+// which will replace the bad file on the next save.
+func LoadPlayHistory(path string) *PlayHistory {
+	ph := &PlayHistory{
+		path:    path,
+		records: map[string]*PlayRecord{},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Error reading play history %v, starting afresh: %v", path, err)
+		}
+		return ph
+	}
+
+	var records map[string]*PlayRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		log.Printf("Play history %v is corrupt, rebuilding it: %v", path, err)
+
+		if err := os.Rename(path, path+".corrupt"); err != nil {
+			log.Printf("Error moving corrupt play history aside: %v", err)
+		}
+		return ph
+	}
+
+	for item, record := range records {
+		if record != nil {
+			ph.records[item] = record
+		}
+	}
+
+	return ph
+}
+
+// save writes the history out. Must be called with the lock held.
+func (ph *PlayHistory) save() {
+	data, err := json.MarshalIndent(ph.records, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding play history: %v", err)
+		return
+	}
+
+	// Write then rename, so that being killed mid-write can't leave us with
+	// a truncated file.
+	tmpPath := ph.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Printf("Error writing play history %v: %v", tmpPath, err)
+		return
+	}
+
+	if err := os.Rename(tmpPath, ph.path); err != nil {
+		log.Printf("Error replacing play history %v: %v", ph.path, err)
+	}
+}
+
+func (ph *PlayHistory) record(item string) *PlayRecord {
+	record, ok := ph.records[item]
+	if !ok {
+		record = &PlayRecord{}
+		ph.records[item] = record
+	}
+	return record
+}
+
+// Started records that item began playing at the given time. This is
+// recorded up front so that other windows choosing at the same moment see it.
+func (ph *PlayHistory) Started(item string, at time.Time) {
+	ph.Lock()
+	defer ph.Unlock()
+
+	record := ph.record(item)
+	record.Count++
+	record.LastPlayed = at
+
+	ph.save()
+}
+
+// Finished records how long item was on screen for.
+func (ph *PlayHistory) Finished(item string, played time.Duration) {
+	ph.Lock()
+	defer ph.Unlock()
+
+	record := ph.record(item)
+	record.LastDuration = played
+	record.TotalDuration += played
+
+	ph.save()
+}
+
+// Get returns a copy of the record for item, if there is one.
+func (ph *PlayHistory) Get(item string) (PlayRecord, bool) {
+	ph.Lock()
+	defer ph.Unlock()
+
+	record, ok := ph.records[item]
+	if !ok {
+		return PlayRecord{}, false
+	}
+	return *record, true
+}
+
+// LeastRecentlyPlayed picks one of items, favouring those never played, then
+// those played longest ago, then those played fewest times. To avoid every
+// window marching through the library in the same order, the choice is made
+// at random from the best quarter of the candidates.
+func (ph *PlayHistory) LeastRecentlyPlayed(items []string, rng *rand.Rand) (string, bool) {
+	if len(items) == 0 {
+		return "", false
+	}
+
+	type candidate struct {
+		item   string
+		record PlayRecord
+	}
+
+	candidates := make([]candidate, 0, len(items))
+
+	ph.Lock()
+	for _, item := range items {
+		var c = candidate{item: item}
+		if record, ok := ph.records[item]; ok {
+			c.record = *record
+		}
+		candidates = append(candidates, c)
+	}
+	ph.Unlock()
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].record, candidates[j].record
+		if !a.LastPlayed.Equal(b.LastPlayed) {
+			return a.LastPlayed.Before(b.LastPlayed)
+		}
+		return a.Count < b.Count
+	})
+
+	pool := (len(candidates) + 3) / 4
+	return candidates[rng.Intn(pool)].item, true
+}
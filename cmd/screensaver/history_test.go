@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlayHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+
+	ph := LoadPlayHistory(path)
+	start := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	ph.Started("a.mp4", start)
+	ph.Finished("a.mp4", 90*time.Second)
+	ph.Started("a.mp4", start.Add(time.Hour))
+
+	record, ok := LoadPlayHistory(path).Get("a.mp4")
+	if !ok {
+		t.Fatal("Record not persisted")
+	}
+	if record.Count != 2 || !record.LastPlayed.Equal(start.Add(time.Hour)) || record.TotalDuration != 90*time.Second {
+		t.Errorf("Unexpected record %+v", record)
+	}
+}
+
+func TestPlayHistoryRebuildsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ph := LoadPlayHistory(path)
+	if _, ok := ph.Get("a.mp4"); ok {
+		t.Error("Corrupt history produced a record")
+	}
+
+	ph.Started("a.mp4", time.Now())
+	if _, ok := LoadPlayHistory(path).Get("a.mp4"); !ok {
+		t.Error("History not rebuilt after corruption")
+	}
+	if _, err := os.Stat(path + ".corrupt"); err != nil {
+		t.Errorf("Corrupt history not kept aside: %v", err)
+	}
+}
+
+func TestLeastRecentlyPlayed(t *testing.T) {
+	ph := LoadPlayHistory(filepath.Join(t.TempDir(), "history.json"))
+	rng := rand.New(rand.NewSource(1))
+
+	now := time.Now()
+	ph.Started("old.mp4", now.Add(-48*time.Hour))
+	ph.Started("new.mp4", now)
+	ph.Started("newer.mp4", now.Add(time.Minute))
+
+	// The one never played always wins with so few candidates
+	for i := 0; i < 10; i++ {
+		item, _ := ph.LeastRecentlyPlayed([]string{"new.mp4", "never.mp4", "old.mp4", "newer.mp4"}, rng)
+		if item != "never.mp4" {
+			t.Fatalf("Expected never.mp4, got %s", item)
+		}
+	}
+
+	item, _ := ph.LeastRecentlyPlayed([]string{"new.mp4", "old.mp4", "newer.mp4"}, rng)
+	if item != "old.mp4" {
+		t.Errorf("Expected old.mp4, got %s", item)
+	}
+
+	if _, ok := ph.LeastRecentlyPlayed(nil, rng); ok {
+		t.Error("Chose from an empty list")
+	}
+}
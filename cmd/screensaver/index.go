@@ -0,0 +1,715 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mediaIndexVersion is bumped whenever the meaning of the index file
+// changes, so that old files are rebuilt rather than misread.
+const mediaIndexVersion = 1
+
+// indexSaveInterval is how many probes we make between saves of the index,
+// so that being killed part way through a large library doesn't lose much.
+const indexSaveInterval = 50
+
+var errIndexClosed = errors.New("media index closed")
+
+// ErrNotIndexed is returned when listing a directory the index hasn't
+// scanned yet. The scan is started in the background.
+var ErrNotIndexed = fmt.Errorf("%w: not indexed yet", ErrNoMediaFound)
+
+// IndexEntry is what we know about one local media file. Size and ModTime
+// come from the directory listing; the rest is filled in by probing, which
+// is only redone when they change.
+type IndexEntry struct {
+	Size      int64         `json:"size"`
+	ModTime   time.Time     `json:"modTime"`
+	Probed    bool          `json:"probed,omitempty"`
+	Duration  time.Duration `json:"duration,omitempty"`
+	Width     int           `json:"width,omitempty"`
+	Height    int           `json:"height,omitempty"`
+	Codec     string        `json:"codec,omitempty"`
+	LastError string        `json:"lastError,omitempty"`
+}
+
+// Geometry returns the display size of the file, if probing found one.
+func (ie *IndexEntry) Geometry() (VideoGeometry, bool) {
+	if ie.Width <= 0 || ie.Height <= 0 {
+		return VideoGeometry{}, false
+	}
+	return VideoGeometry{Width: ie.Width, Height: ie.Height}, true
+}
+
+func (ie *IndexEntry) unchanged(info fs.FileInfo) bool {
+	return ie.Size == info.Size() && ie.ModTime.Equal(info.ModTime())
+}
+
+// indexedRoot records how a directory was last scanned, and so which files
+// under it the index can answer for.
+type indexedRoot struct {
+	Extensions []string  `json:"extensions"`
+	Recursive  bool      `json:"recursive"`
+	Scanned    time.Time `json:"scanned"`
+}
+
+// covers reports whether a scan of the root found everything that a listing
+// with the given extensions and recursion needs.
+func (ir *indexedRoot) covers(extensions []string, recursive bool) bool {
+	if ir == nil || (recursive && !ir.Recursive) {
+		return false
+	}
+
+	for _, ext := range extensions {
+		if !hasMediaExtension(ext, ir.Extensions) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inRoot reports whether path would be found by a scan of root.
+func inRoot(path, root string, recursive bool) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return recursive || !strings.ContainsRune(rel, filepath.Separator)
+}
+
+// mediaIndexFile is the on-disk form of a MediaIndex.
+type mediaIndexFile struct {
+	Version int                     `json:"version"`
+	Roots   map[string]*indexedRoot `json:"roots"`
+	Entries map[string]*IndexEntry  `json:"entries"`
+}
+
+// ProbeFunc works out the duration, size and codec of the file at path.
+type ProbeFunc func(path string) (IndexEntry, error)
+
+// MediaIndex is a persisted listing of the media files under each directory
+// source, along with what probing them and the local entries of playlists
+// found. Listings are served from the
+// index straight away, and brought up to date by a background rescan that
+// only probes files whose size or modification time has changed.
+type MediaIndex struct {
+	sync.Mutex
+
+	// Probe is used to fill in entries. If it is nil, nothing is probed.
+	Probe ProbeFunc
+
+	path    string
+	roots   map[string]*indexedRoot
+	entries map[string]*IndexEntry
+	dirty   bool
+
+	// scanLocks stops two scans of the same root running at once, and
+	// scanning records the roots being scanned for the first time.
+	scanLocks map[string]*sync.Mutex
+	scanning  map[string]bool
+
+	// configured holds the roots of the directory sources, once known. Other
+	// roots are dropped when the index is saved.
+	configured map[string]bool
+
+	// queued holds files outside any indexed directory waiting to be
+	// probed, and probeScheduled is set while a background probe of them is
+	// yet to start.
+	queued         map[string]bool
+	probeScheduled bool
+
+	stop       chan struct{}
+	stopOnce   sync.Once
+	background sync.WaitGroup
+}
+
+// LoadMediaIndex reads the index stored at path. As with the play history,
+// a missing or corrupt file just means starting again with an empty index.
+func LoadMediaIndex(path string) *MediaIndex {
+	mi := &MediaIndex{
+		path:      path,
+		roots:     map[string]*indexedRoot{},
+		entries:   map[string]*IndexEntry{},
+		scanLocks: map[string]*sync.Mutex{},
+		scanning:  map[string]bool{},
+		queued:    map[string]bool{},
+		stop:      make(chan struct{}),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Error reading media index %v, starting afresh: %v", path, err)
+		}
+		return mi
+	}
+
+	var file mediaIndexFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Printf("Media index %v is corrupt, rebuilding it: %v", path, err)
+
+		if err := os.Rename(path, path+".corrupt"); err != nil {
+			log.Printf("Error moving corrupt media index aside: %v", err)
+		}
+		return mi
+	}
+
+	if file.Version != mediaIndexVersion {
+		log.Printf("Media index %v is version %d, rebuilding it", path, file.Version)
+		return mi
+	}
+
+	for root, indexed := range file.Roots {
+		if indexed != nil {
+			mi.roots[root] = indexed
+		}
+	}
+	for path, entry := range file.Entries {
+		if entry != nil {
+			mi.entries[path] = entry
+		}
+	}
+
+	return mi
+}
+
+// save writes the index out if it has changed. Must be called with the lock
+// held.
+func (mi *MediaIndex) save() {
+	mi.pruneRoots()
+
+	if !mi.dirty {
+		return
+	}
+
+	data, err := json.Marshal(mediaIndexFile{
+		Version: mediaIndexVersion,
+		Roots:   mi.roots,
+		Entries: mi.entries,
+	})
+	if err != nil {
+		log.Printf("Error encoding media index: %v", err)
+		return
+	}
+
+	tmpPath := mi.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Printf("Error writing media index %v: %v", tmpPath, err)
+		return
+	}
+
+	if err := os.Rename(tmpPath, mi.path); err != nil {
+		log.Printf("Error replacing media index %v: %v", mi.path, err)
+		return
+	}
+
+	mi.dirty = false
+}
+
+// pruneRoots drops directories that are no longer sources, along with the
+// files found under them, unless another root covers them. Must be called
+// with the lock held.
+func (mi *MediaIndex) pruneRoots() {
+	if mi.configured == nil {
+		return
+	}
+
+	for root, indexed := range mi.roots {
+		if mi.configured[root] {
+			continue
+		}
+
+		log.Printf("Dropping %v from the index, as it is no longer a source", root)
+		delete(mi.roots, root)
+		for path := range mi.entries {
+			if inRoot(path, root, indexed.Recursive) && mi.indexedRootFor(path, false) == nil {
+				delete(mi.entries, path)
+			}
+		}
+		mi.dirty = true
+	}
+}
+
+// Save writes the index out if it has changed.
+func (mi *MediaIndex) Save() {
+	mi.Lock()
+	defer mi.Unlock()
+
+	mi.save()
+}
+
+// Items lists the media files under root, from the index however stale it
+// is. If the index has never scanned root, or not with these settings, a
+// scan is started in the background, and until it finishes, Items lists
+// what it can or returns ErrNotIndexed.
+func (mi *MediaIndex) Items(root string, extensions []string, recursive bool) ([]MediaItem, error) {
+	mi.Lock()
+	covered := mi.roots[root].covers(extensions, recursive)
+	mi.Unlock()
+
+	if !covered {
+		mi.scanInBackground(root, extensions, recursive)
+	}
+
+	var items []MediaItem
+
+	mi.Lock()
+	for path, entry := range mi.entries {
+		if inRoot(path, root, recursive) && hasMediaExtension(path, extensions) {
+			items = append(items, MediaItem{Location: path, Duration: entry.Duration})
+		}
+	}
+	mi.Unlock()
+
+	if len(items) == 0 {
+		if !covered {
+			return nil, ErrNotIndexed
+		}
+		return nil, ErrNoMediaFound
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Location < items[j].Location
+	})
+	return items, nil
+}
+
+// scanInBackground scans root on another goroutine, unless that is already
+// under way. Close waits for it to stop.
+func (mi *MediaIndex) scanInBackground(root string, extensions []string, recursive bool) {
+	mi.Lock()
+	defer mi.Unlock()
+
+	if mi.scanning[root] {
+		return
+	}
+	select {
+	case <-mi.stop:
+		return
+	default:
+	}
+	mi.scanning[root] = true
+
+	mi.background.Add(1)
+	go func() {
+		defer mi.background.Done()
+
+		if err := mi.rescan(root, extensions, recursive, time.Time{}); err != nil && err != errIndexClosed {
+			log.Printf("Error indexing %v: %v", root, err)
+		}
+
+		mi.Lock()
+		delete(mi.scanning, root)
+		mi.Unlock()
+	}()
+}
+
+func (mi *MediaIndex) scanLock(root string) *sync.Mutex {
+	mi.Lock()
+	defer mi.Unlock()
+
+	lock, ok := mi.scanLocks[root]
+	if !ok {
+		lock = &sync.Mutex{}
+		mi.scanLocks[root] = lock
+	}
+	return lock
+}
+
+// Rescan walks root, updating the index with what has been added, removed
+// or changed since it was last scanned.
+func (mi *MediaIndex) Rescan(root string, extensions []string, recursive bool) error {
+	return mi.rescan(root, extensions, recursive, time.Now())
+}
+
+// rescan is Rescan, except that it does nothing if root has been scanned
+// with suitable settings since the given time.
+func (mi *MediaIndex) rescan(root string, extensions []string, recursive bool, since time.Time) error {
+	lock := mi.scanLock(root)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Never narrow what we know about a root: if it has been scanned more
+	// widely before, scan it that way again.
+	mi.Lock()
+	if indexed := mi.roots[root]; indexed.covers(extensions, recursive) {
+		if indexed.Scanned.After(since) {
+			mi.Unlock()
+			return nil
+		}
+		extensions, recursive = indexed.Extensions, indexed.Recursive
+	}
+	mi.Unlock()
+
+	started := time.Now()
+	found := map[string]fs.FileInfo{}
+	dirs := map[string]bool{}
+
+	err := walkMediaLibrary(root, extensions, recursive, func(path string, entry fs.DirEntry) error {
+		select {
+		case <-mi.stop:
+			return errIndexClosed
+		default:
+		}
+
+		if info, err := entry.Info(); err == nil {
+			found[path] = info
+			dirs[filepath.Dir(path)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The clips' sidecars are read now too, so that choosing what to play
+	// doesn't have to.
+	for dir := range dirs {
+		mediaSidecars.Reload(dir)
+	}
+
+	mi.Lock()
+	defer mi.Unlock()
+
+	var added, changed, removed int
+
+	for path, info := range found {
+		entry, ok := mi.entries[path]
+		if ok && entry.unchanged(info) {
+			continue
+		}
+
+		if ok {
+			changed++
+		} else {
+			added++
+		}
+		mi.entries[path] = &IndexEntry{Size: info.Size(), ModTime: info.ModTime()}
+	}
+
+	for path := range mi.entries {
+		if _, ok := found[path]; !ok && inRoot(path, root, recursive) && hasMediaExtension(path, extensions) {
+			delete(mi.entries, path)
+			removed++
+		}
+	}
+
+	mi.roots[root] = &indexedRoot{
+		Extensions: extensions,
+		Recursive:  recursive,
+		Scanned:    time.Now(),
+	}
+	mi.dirty = true
+	mi.save()
+
+	log.Printf("Indexed %v in %v: %d files, %d added, %d changed, %d removed",
+		root, time.Since(started).Round(time.Millisecond), len(found), added, changed, removed)
+
+	return nil
+}
+
+// indexedRootFor returns the root, if any, whose scans would find path. Must
+// be called with the lock held.
+func (mi *MediaIndex) indexedRootFor(path string, isDir bool) *indexedRoot {
+	for root, indexed := range mi.roots {
+		if !inRoot(path, root, indexed.Recursive) {
+			continue
+		}
+		if isDir || hasMediaExtension(path, indexed.Extensions) {
+			return indexed
+		}
+	}
+	return nil
+}
+
+// Update brings the index into line with a single path that has been
+// created, changed, removed or renamed. Removing a directory removes
+// everything under it; creating one indexes its contents. Paths that no
+// indexed root covers are ignored.
+func (mi *MediaIndex) Update(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			mi.remove(path)
+		}
+		return
+	}
+
+	if isHiddenOrSystemFile(info) {
+		return
+	}
+
+	if !info.IsDir() {
+		mi.Lock()
+		defer mi.Unlock()
+
+		if mi.indexedRootFor(path, false) != nil {
+			mi.updateFile(path, info)
+		}
+		return
+	}
+
+	mi.Lock()
+	indexed := mi.indexedRootFor(path, true)
+	mi.Unlock()
+
+	if indexed == nil || !indexed.Recursive {
+		return
+	}
+
+	found := map[string]fs.FileInfo{}
+	walkMediaLibrary(path, indexed.Extensions, true, func(path string, entry fs.DirEntry) error {
+		if info, err := entry.Info(); err == nil {
+			found[path] = info
+		}
+		return nil
+	})
+
+	mi.Lock()
+	defer mi.Unlock()
+
+	for path, info := range found {
+		mi.updateFile(path, info)
+	}
+}
+
+// updateFile records path as needing probing, unless we already know about
+// it as it is. Must be called with the lock held.
+func (mi *MediaIndex) updateFile(path string, info fs.FileInfo) {
+	if entry, ok := mi.entries[path]; ok && entry.unchanged(info) {
+		return
+	}
+
+	log.Printf("Indexing new or changed file %v", path)
+	mi.entries[path] = &IndexEntry{Size: info.Size(), ModTime: info.ModTime()}
+	mi.dirty = true
+}
+
+// remove drops path, and anything under it, from the index.
+func (mi *MediaIndex) remove(path string) {
+	mi.Lock()
+	defer mi.Unlock()
+
+	prefix := path + string(filepath.Separator)
+	for entryPath := range mi.entries {
+		if entryPath == path || strings.HasPrefix(entryPath, prefix) {
+			log.Printf("Removing %v from the index", entryPath)
+			delete(mi.entries, entryPath)
+			mi.dirty = true
+		}
+	}
+}
+
+// Refresh rescans the directories among sources, then probes any files that
+// need it. Directories that are no longer among sources are dropped from the
+// index when it is next saved. It is meant to be run in the background; see
+// RefreshInBackground.
+func (mi *MediaIndex) Refresh(sources []MediaSource, extensions []string) {
+	started := time.Now()
+
+	configured := map[string]bool{}
+	for _, source := range sources {
+		if source.Type == DirectorySource {
+			configured[source.Path] = true
+		}
+	}
+	mi.Lock()
+	mi.configured = configured
+	mi.Unlock()
+
+	for _, source := range sources {
+		if source.Type != DirectorySource || !source.Enabled {
+			continue
+		}
+
+		if err := mi.rescan(source.Path, extensions, source.Recursive, started); err != nil {
+			if err == errIndexClosed {
+				return
+			}
+			log.Printf("Error indexing %v: %v", source.Path, err)
+		}
+	}
+
+	mi.probePending()
+}
+
+// RefreshInBackground runs Refresh on another goroutine. Close waits for it
+// to stop.
+func (mi *MediaIndex) RefreshInBackground(sources []MediaSource, extensions []string) {
+	mi.background.Add(1)
+	go func() {
+		defer mi.background.Done()
+		mi.Refresh(sources, extensions)
+	}()
+}
+
+// Close stops any background refresh and saves the index.
+func (mi *MediaIndex) Close() {
+	mi.stopOnce.Do(func() { close(mi.stop) })
+	mi.background.Wait()
+	mi.Save()
+}
+
+// Enqueue has local files outside any indexed directory, such as the
+// entries of playlists, probed in the background, so that their shapes are
+// known. Files already in the index are left as they are.
+func (mi *MediaIndex) Enqueue(paths []string) {
+	var added bool
+
+	mi.Lock()
+	for _, path := range paths {
+		if isMediaURL(path) || mi.queued[path] {
+			continue
+		}
+		if _, ok := mi.entries[path]; ok {
+			continue
+		}
+		mi.queued[path] = true
+		added = true
+	}
+	schedule := added && !mi.probeScheduled
+	if schedule {
+		mi.probeScheduled = true
+	}
+	mi.Unlock()
+
+	if !schedule {
+		return
+	}
+
+	select {
+	case <-mi.stop:
+		return
+	default:
+	}
+
+	mi.background.Add(1)
+	go func() {
+		defer mi.background.Done()
+
+		mi.Lock()
+		mi.probeScheduled = false
+		mi.Unlock()
+
+		mi.probePending()
+	}()
+}
+
+// probePending probes every entry that hasn't been probed since it last
+// changed, and every file queued for probing.
+func (mi *MediaIndex) probePending() {
+	if mi.Probe == nil {
+		return
+	}
+
+	var pending []string
+
+	mi.Lock()
+	for path, entry := range mi.entries {
+		if !entry.Probed {
+			pending = append(pending, path)
+		}
+	}
+	for path := range mi.queued {
+		if _, ok := mi.entries[path]; !ok {
+			pending = append(pending, path)
+		}
+	}
+	mi.queued = map[string]bool{}
+	mi.Unlock()
+
+	sort.Strings(pending)
+
+	for i, path := range pending {
+		select {
+		case <-mi.stop:
+			return
+		default:
+		}
+
+		mi.probe(path)
+
+		if (i+1)%indexSaveInterval == 0 {
+			mi.Save()
+		}
+	}
+
+	mi.Save()
+
+	if len(pending) > 0 {
+		log.Printf("Probed %d media files", len(pending))
+	}
+}
+
+// probe fills in the entry for path, if it still needs it, and returns it.
+func (mi *MediaIndex) probe(path string) *IndexEntry {
+	mi.Lock()
+	entry, ok := mi.entries[path]
+	mi.Unlock()
+
+	if ok && entry.Probed {
+		return entry
+	}
+
+	// Files outside any indexed directory, queued by Enqueue, are added as
+	// they are probed.
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if !ok || !entry.unchanged(info) {
+		entry = &IndexEntry{Size: info.Size(), ModTime: info.ModTime()}
+	}
+
+	probed, err := mi.Probe(path)
+
+	var updated = *entry
+	updated.Probed = true
+	updated.Duration = probed.Duration
+	updated.Width = probed.Width
+	updated.Height = probed.Height
+	updated.Codec = probed.Codec
+	updated.LastError = ""
+	if err != nil {
+		log.Printf("Unable to probe %v: %v", path, err)
+		updated.LastError = err.Error()
+	}
+
+	mi.Lock()
+	defer mi.Unlock()
+
+	// Don't overwrite the result of a rescan that ran while we were probing.
+	if current, ok := mi.entries[path]; ok && !current.unchanged(info) {
+		return current
+	}
+
+	mi.entries[path] = &updated
+	mi.dirty = true
+	return &updated
+}
+
+// Geometry returns the display size of the clip at location, if it has been
+// probed. It only looks in the index, never probing clips itself, as it is
+// called while choosing what to play: clips are of unknown shape until the
+// background refresh gets to them.
+func (mi *MediaIndex) Geometry(location string) (VideoGeometry, bool) {
+	mi.Lock()
+	entry, ok := mi.entries[location]
+	mi.Unlock()
+
+	if !ok || !entry.Probed {
+		return VideoGeometry{}, false
+	}
+
+	return entry.Geometry()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMediaIndexIncrementalRescan(t *testing.T) {
+	root := t.TempDir()
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+
+	for _, name := range []string{"a.mp4", "b.mkv", filepath.Join("sub", "c.mov"), "notes.txt"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var probed []string
+	probe := func(path string) (IndexEntry, error) {
+		probed = append(probed, filepath.Base(path))
+		return IndexEntry{Duration: time.Minute, Width: 1920, Height: 1080, Codec: "H264"}, nil
+	}
+
+	mi := LoadMediaIndex(indexPath)
+	mi.Probe = probe
+
+	// Never scanned, so the first listing starts a scan in the background.
+	if items, err := mi.Items(root, DefaultMediaExtensions, true); !errors.Is(err, ErrNoMediaFound) {
+		t.Fatalf("Expected nothing until the directory is scanned, got %v, %v", items, err)
+	}
+	waitForItems(t, mi, root, "a.mp4", "b.mkv", filepath.Join("sub", "c.mov"))
+
+	mi.Refresh([]MediaSource{newMediaSource(root)}, DefaultMediaExtensions)
+	if len(probed) != 3 {
+		t.Errorf("Expected every file to be probed, probed %v", probed)
+	}
+	mi.Close()
+
+	// Change one file, remove one and add one.
+	later := time.Now().Add(time.Hour)
+	if err := os.WriteFile(filepath.Join(root, "a.mp4"), []byte("longer contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(root, "a.mp4"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "b.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "d.webm"), []byte("d"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mi = LoadMediaIndex(indexPath)
+	mi.Probe = probe
+	probed = nil
+
+	// The listing comes from the index as it was, without rescanning.
+	items, err := mi.Items(root, DefaultMediaExtensions, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || items[0].Duration != time.Minute {
+		t.Errorf("Expected the cached listing, got %v", items)
+	}
+
+	// New clips are of unknown shape until the refresh probes them.
+	if g, ok := mi.Geometry(filepath.Join(root, "d.webm")); ok || len(probed) > 0 {
+		t.Errorf("Expected unprobed geometry to be unknown without probing, got %v, probed %v", g, probed)
+	}
+
+	mi.Refresh([]MediaSource{newMediaSource(root)}, DefaultMediaExtensions)
+	if expected := []string{"a.mp4", "d.webm"}; !reflect.DeepEqual(probed, expected) {
+		t.Errorf("Expected only %v to be probed, probed %v", expected, probed)
+	}
+
+	items, _ = mi.Items(root, DefaultMediaExtensions, true)
+	var locations []string
+	for _, item := range items {
+		locations = append(locations, item.Location)
+	}
+	expected := []string{
+		filepath.Join(root, "a.mp4"),
+		filepath.Join(root, "d.webm"),
+		filepath.Join(root, "sub", "c.mov"),
+	}
+	if !reflect.DeepEqual(locations, expected) {
+		t.Errorf("Expected %v after rescan, got %v", expected, locations)
+	}
+
+	// A non-recursive listing is served from the recursive scan.
+	items, _ = mi.Items(root, DefaultMediaExtensions, false)
+	if len(items) != 2 {
+		t.Errorf("Expected 2 items without subfolders, got %v", items)
+	}
+
+	if g, ok := mi.Geometry(filepath.Join(root, "d.webm")); !ok || g.Width != 1920 {
+		t.Errorf("Expected probed geometry, got %v %v", g, ok)
+	}
+	mi.Close()
+}
+
+func TestMediaIndexDropsUnconfiguredRoots(t *testing.T) {
+	kept, dropped := t.TempDir(), t.TempDir()
+	writeTestFile(t, filepath.Join(kept, "a.mp4"), "a")
+	writeTestFile(t, filepath.Join(dropped, "b.mp4"), "b")
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+
+	mi := LoadMediaIndex(indexPath)
+	for _, root := range []string{kept, dropped} {
+		if err := mi.Rescan(root, DefaultMediaExtensions, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mi.Close()
+
+	mi = LoadMediaIndex(indexPath)
+	mi.Refresh([]MediaSource{newMediaSource(kept)}, DefaultMediaExtensions)
+	mi.Close()
+
+	mi = LoadMediaIndex(indexPath)
+	if _, ok := mi.roots[dropped]; ok {
+		t.Errorf("Expected %v to be dropped from the index", dropped)
+	}
+	if _, ok := mi.entries[filepath.Join(dropped, "b.mp4")]; ok {
+		t.Errorf("Expected the files under %v to be dropped", dropped)
+	}
+	if _, ok := mi.entries[filepath.Join(kept, "a.mp4")]; !ok {
+		t.Errorf("Expected the files under %v to be kept", kept)
+	}
+}
+
+func TestMediaIndexCorrupt(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	if err := os.WriteFile(indexPath, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mi := LoadMediaIndex(indexPath)
+	if len(mi.entries) != 0 {
+		t.Errorf("Expected an empty index, got %v", mi.entries)
+	}
+	if _, err := os.Stat(indexPath + ".corrupt"); err != nil {
+		t.Errorf("Expected the corrupt index to be moved aside: %v", err)
+	}
+}
+
+func TestMediaIndexEnqueue(t *testing.T) {
+	dir := t.TempDir()
+	clip := filepath.Join(dir, "portrait.mp4")
+	writeTestFile(t, clip, "clip")
+
+	probed := make(chan string, 10)
+	mi := LoadMediaIndex(filepath.Join(t.TempDir(), "index.json"))
+	mi.Probe = func(path string) (IndexEntry, error) {
+		probed <- path
+		return IndexEntry{Width: 1080, Height: 1920}, nil
+	}
+	defer mi.Close()
+
+	if _, ok := mi.Geometry(clip); ok {
+		t.Fatalf("Expected no geometry before probing")
+	}
+
+	mi.Enqueue([]string{clip, "http://elsewhere/clip.mp4"})
+	select {
+	case path := <-probed:
+		if path != clip {
+			t.Errorf("Expected %v to be probed, probed %v", clip, path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected %v to be probed", clip)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if g, ok := mi.Geometry(clip); ok {
+			if g.Width != 1080 {
+				t.Errorf("Unexpected geometry %v", g)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %v to have a geometry once probed", clip)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Once known, it isn't probed again.
+	mi.Enqueue([]string{clip})
+	mi.Close()
+	select {
+	case path := <-probed:
+		t.Errorf("Expected nothing more to be probed, probed %v", path)
+	default:
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// MediaItem is something we can hand to libvlc: either a local path or a URL.
+// Playlists can give us a title and duration for their entries too. Playback
+// comes from the source the item was chosen from and the item's sidecars,
+// which also give its weight within the source and its tags.
+type MediaItem struct {
+	Location string
+	Title    string
+	Duration time.Duration
+	Playback PlaybackOptions
+	Weight   float64
+	Tags     []string
+}
+
+// HasTag reports whether the item is tagged tag, ignoring case.
+func (mi MediaItem) HasTag(tag string) bool {
+	for _, t := range mi.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the title, if we know one, with the location.
+func (mi MediaItem) String() string {
+	if len(mi.Title) > 0 {
+		return mi.Title + " (" + mi.Location + ")"
+	}
+	return mi.Location
+}
+
+// DefaultMediaExtensions is the set of container formats we will hand to
+// libvlc when no MediaExtensions registry value has been configured.
+var DefaultMediaExtensions = []string{
+	".avi", ".flv", ".m2ts", ".m4v", ".mkv", ".mov", ".mp4",
+	".mpeg", ".mpg", ".mts", ".ogv", ".ts", ".webm", ".wmv",
+}
+
+var ErrNoMediaFound = errors.New("no playable media found")
+
+// parseMediaExtensions turns a semicolon separated list such as
+// "mp4;.MKV; mov" into normalised, dot-prefixed, lower case extensions.
+func parseMediaExtensions(list string) []string {
+	var ret []string
+
+	for _, ext := range strings.Split(list, ";") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if len(ext) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		ret = append(ret, ext)
+	}
+
+	return ret
+}
+
+func hasMediaExtension(name string, extensions []string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	if len(ext) == 0 {
+		return false
+	}
+
+	for _, allowed := range extensions {
+		if ext == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHiddenOrSystem reports whether Explorer would hide this entry by
+// default: either it is dot-prefixed, or it carries the hidden or system
+// attribute (desktop.ini, Thumbs.db, $RECYCLE.BIN and friends).
+func isHiddenOrSystem(entry fs.DirEntry) bool {
+	if strings.HasPrefix(entry.Name(), ".") {
+		return true
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return true
+	}
+
+	return isHiddenOrSystemFile(info)
+}
+
+// isHiddenOrSystemFile is isHiddenOrSystem for when we already have the
+// file's info.
+func isHiddenOrSystemFile(info fs.FileInfo) bool {
+	if strings.HasPrefix(info.Name(), ".") {
+		return true
+	}
+
+	if attrs, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return attrs.FileAttributes&(syscall.FILE_ATTRIBUTE_HIDDEN|syscall.FILE_ATTRIBUTE_SYSTEM) != 0
+	}
+
+	return false
+}
+
+// walkMediaLibrary walks root and calls fn for each regular file whose
+// extension is in the allowlist. Hidden and system files and directories are
+// skipped, as are entries we cannot read. Subdirectories are only descended
+// into if recursive is set. If fn returns an error, the walk stops and
+// returns it.
+func walkMediaLibrary(root string, extensions []string, recursive bool, fn func(path string, entry fs.DirEntry) error) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			// Unreadable subdirectories shouldn't stop us playing what we can
+			// read.
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if path == root {
+			return nil
+		}
+
+		if isHiddenOrSystem(entry) || (entry.IsDir() && !recursive) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !entry.Type().IsRegular() || !hasMediaExtension(entry.Name(), extensions) {
+			return nil
+		}
+
+		return fn(path, entry)
+	})
+}
+
+// scanMediaLibrary returns the sorted paths of all the media files
+// walkMediaLibrary finds under root.
+func scanMediaLibrary(root string, extensions []string, recursive bool) ([]string, error) {
+	var ret []string
+
+	err := walkMediaLibrary(root, extensions, recursive, func(path string, entry fs.DirEntry) error {
+		ret = append(ret, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ret) == 0 {
+		return nil, ErrNoMediaFound
+	}
+
+	sort.Strings(ret)
+	return ret, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseMediaExtensions(t *testing.T) {
+	var got = parseMediaExtensions("mp4;.MKV; mov ;;")
+	var expected = []string{".mp4", ".mkv", ".mov"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseMediaExtensions returned %v, expected %v", got, expected)
+	}
+}
+
+func TestScanMediaLibrary(t *testing.T) {
+	root := t.TempDir()
+
+	for _, name := range []string{
+		"a.mp4",
+		"desktop.ini",
+		"notes.txt",
+		"2021/party/b.MKV",
+		"2021/party/c.mov.part",
+		".hidden/d.mp4",
+		"2022/.e.mp4",
+		"2022/f.avi",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A directory named like a video must not be returned
+	if err := os.MkdirAll(filepath.Join(root, "folder.mp4"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := scanMediaLibrary(root, DefaultMediaExtensions, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = []string{
+		filepath.Join(root, "2021", "party", "b.MKV"),
+		filepath.Join(root, "2022", "f.avi"),
+		filepath.Join(root, "a.mp4"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("scanMediaLibrary returned %v, expected %v", files, expected)
+	}
+
+	files, err = scanMediaLibrary(root, DefaultMediaExtensions, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, expected[2:]) {
+		t.Errorf("Non-recursive scanMediaLibrary returned %v, expected %v", files, expected[2:])
+	}
+
+	if _, err := scanMediaLibrary(filepath.Join(root, "2021", "party"), []string{".webm"}, true); err != ErrNoMediaFound {
+		t.Errorf("Expected ErrNoMediaFound, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultMirrorTimeout is how long monitors that have finished a clip wait
+// for the others before moving on without them.
+const DefaultMirrorTimeout = 30 * time.Second
+
+// parseMirrorTimeout reads a timeout in whole seconds, as stored in the
+// registry. Zero waits for every monitor however long it takes.
+func parseMirrorTimeout(value string) time.Duration {
+	if len(value) == 0 {
+		return DefaultMirrorTimeout
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		log.Printf("Invalid mirror timeout %q, using %v", value, DefaultMirrorTimeout)
+		return DefaultMirrorTimeout
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// ErrMirrorSkipped is returned to a monitor asking again for a clip it has
+// already had, because it failed to play it. Rather than pick another clip,
+// and get out of step with the other monitors, it sits the clip out.
+var ErrMirrorSkipped = errors.New("monitor is sitting out this clip")
+
+// mirrorGroup keeps the monitors in mirror mode showing the same clip. They
+// all take their clips from one selector, and move on to each clip together
+// once all of them have finished the last, or some of them have waited for
+// the others for Timeout. Members are numbered from 0.
+type mirrorGroup struct {
+	sync.Mutex
+
+	Timeout time.Duration
+
+	pick    func() (MediaItem, error)
+	members int
+	// round counts the clips played so far; picks holds the clips for the
+	// current and next rounds as they are chosen.
+	round int
+	picks map[int]MediaItem
+	// taken is, for each member, the last round it was given the clip for.
+	taken map[int]int
+	// advance holds, for each member, how to start it on the next round.
+	advance map[int]func()
+	// finished holds the members that are done with the current round.
+	finished map[int]bool
+	timer    *time.Timer
+}
+
+// newMirrorGroup creates a group of members monitors, choosing clips with
+// pick.
+func newMirrorGroup(members int, pick func() (MediaItem, error)) *mirrorGroup {
+	return &mirrorGroup{
+		Timeout:  DefaultMirrorTimeout,
+		pick:     pick,
+		members:  members,
+		picks:    map[int]MediaItem{},
+		taken:    map[int]int{},
+		advance:  map[int]func(){},
+		finished: map[int]bool{},
+	}
+}
+
+// Join tells the group how to start member on the next round.
+func (mg *mirrorGroup) Join(member int, advance func()) {
+	mg.Lock()
+	defer mg.Unlock()
+
+	mg.advance[member] = advance
+}
+
+// Item returns the clip member is to play: the current round's, or when ahead
+// is set, the next round's, for it to have ready.
+func (mg *mirrorGroup) Item(member int, ahead bool) (MediaItem, error) {
+	mg.Lock()
+	defer mg.Unlock()
+
+	round := mg.round
+	if ahead {
+		round++
+	}
+
+	if taken, ok := mg.taken[member]; ok && taken >= round {
+		return MediaItem{}, ErrMirrorSkipped
+	}
+
+	item, ok := mg.picks[round]
+	if !ok {
+		var err error
+		if item, err = mg.pick(); err != nil {
+			return MediaItem{}, err
+		}
+		mg.picks[round] = item
+	}
+
+	mg.taken[member] = round
+	return item, nil
+}
+
+// Finished records that member is done with the current round, whether it
+// played to the end or failed. Once every member is done, or the first has
+// waited Timeout for the rest, every member is started on the next round,
+// including any still playing the last.
+func (mg *mirrorGroup) Finished(member int) {
+	mg.Lock()
+
+	if mg.finished[member] {
+		mg.Unlock()
+		return
+	}
+	mg.finished[member] = true
+
+	if len(mg.finished) < mg.members {
+		if mg.timer == nil && mg.Timeout > 0 {
+			round := mg.round
+			mg.timer = time.AfterFunc(mg.Timeout, func() {
+				mg.timedOut(round)
+			})
+		}
+		mg.Unlock()
+		return
+	}
+
+	advances := mg.nextRound()
+	mg.Unlock()
+
+	for _, advance := range advances {
+		advance()
+	}
+}
+
+func (mg *mirrorGroup) timedOut(round int) {
+	mg.Lock()
+	if round != mg.round {
+		// The round finished just as the timer fired.
+		mg.Unlock()
+		return
+	}
+
+	log.Printf("Only %d of %d monitors finished clip %d in %v, moving on without the rest",
+		len(mg.finished), mg.members, round, mg.Timeout)
+
+	advances := mg.nextRound()
+	mg.Unlock()
+
+	for _, advance := range advances {
+		advance()
+	}
+}
+
+// nextRound starts the next round, returning the members' advance
+// functions to call once the lock is released. Must be called with the lock
+// held.
+func (mg *mirrorGroup) nextRound() []func() {
+	if mg.timer != nil {
+		mg.timer.Stop()
+		mg.timer = nil
+	}
+
+	delete(mg.picks, mg.round)
+	mg.round++
+	mg.finished = map[int]bool{}
+
+	var advances []func()
+	for _, advance := range mg.advance {
+		advances = append(advances, advance)
+	}
+
+	return advances
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestMirrorGroup returns a group picking clips numbered in order, and a
+// channel on which each member's number is sent as it is advanced.
+func newTestMirrorGroup(members int) (*mirrorGroup, chan int) {
+	var picked int
+	mg := newMirrorGroup(members, func() (MediaItem, error) {
+		picked++
+		return MediaItem{Location: fmt.Sprintf("clip%d.mp4", picked)}, nil
+	})
+
+	advanced := make(chan int, 16)
+	for member := 0; member < members; member++ {
+		member := member
+		mg.Join(member, func() { advanced <- member })
+	}
+	return mg, advanced
+}
+
+func TestMirrorGroupSharesClips(t *testing.T) {
+	mg, _ := newTestMirrorGroup(3)
+
+	for member := 0; member < 3; member++ {
+		item, err := mg.Item(member, false)
+		if err != nil || item.Location != "clip1.mp4" {
+			t.Errorf("Expected member %d to play clip1.mp4, got %v, %v", member, item, err)
+		}
+		next, err := mg.Item(member, true)
+		if err != nil || next.Location != "clip2.mp4" {
+			t.Errorf("Expected member %d to prepare clip2.mp4, got %v, %v", member, next, err)
+		}
+	}
+}
+
+func TestMirrorGroupSkipsRepeatedRequests(t *testing.T) {
+	mg, _ := newTestMirrorGroup(2)
+
+	if _, err := mg.Item(0, false); err != nil {
+		t.Fatal(err)
+	}
+	// The clip failed to load, and the widget asks for another.
+	if _, err := mg.Item(0, false); !errors.Is(err, ErrMirrorSkipped) {
+		t.Errorf("Expected ErrMirrorSkipped, got %v", err)
+	}
+	// Having sat that clip out, the next is still available.
+	if item, err := mg.Item(0, true); err != nil || item.Location != "clip2.mp4" {
+		t.Errorf("Expected clip2.mp4, got %v, %v", item, err)
+	}
+}
+
+func TestMirrorGroupWaitsForAllMembers(t *testing.T) {
+	mg, advanced := newTestMirrorGroup(3)
+	mg.Timeout = time.Hour
+	for member := 0; member < 3; member++ {
+		mg.Item(member, false)
+	}
+
+	mg.Finished(0)
+	mg.Finished(2)
+	mg.Finished(2)
+	select {
+	case member := <-advanced:
+		t.Fatalf("Member %d advanced before all had finished", member)
+	default:
+	}
+
+	mg.Finished(1)
+	seen := map[int]bool{}
+	for i := 0; i < 3; i++ {
+		seen[<-advanced] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected all members to advance, got %v", seen)
+	}
+
+	for member := 0; member < 3; member++ {
+		if item, err := mg.Item(member, false); err != nil || item.Location != "clip2.mp4" {
+			t.Errorf("Expected member %d to move on to clip2.mp4, got %v, %v", member, item, err)
+		}
+	}
+}
+
+func TestMirrorGroupTimesOut(t *testing.T) {
+	mg, advanced := newTestMirrorGroup(2)
+	mg.Timeout = 10 * time.Millisecond
+
+	mg.Item(0, false)
+	mg.Item(1, false)
+	mg.Finished(0)
+
+	// Member 1 has stalled, so the group moves on without it.
+	seen := map[int]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case member := <-advanced:
+			seen[member] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for members to advance, got %v", seen)
+		}
+	}
+
+	for member := 0; member < 2; member++ {
+		if item, err := mg.Item(member, false); err != nil || item.Location != "clip2.mp4" {
+			t.Errorf("Expected member %d to move on to clip2.mp4, got %v, %v", member, item, err)
+		}
+	}
+}
+
+func TestMirrorGroupFailedMemberDoesNotStall(t *testing.T) {
+	mg, advanced := newTestMirrorGroup(2)
+	mg.Timeout = time.Hour
+
+	var wg sync.WaitGroup
+	for member := 0; member < 2; member++ {
+		wg.Add(1)
+		go func(member int) {
+			defer wg.Done()
+			mg.Item(member, false)
+			if member == 1 {
+				// It can't play the clip, so sits it out.
+				if _, err := mg.Item(member, false); !errors.Is(err, ErrMirrorSkipped) {
+					t.Errorf("Expected ErrMirrorSkipped, got %v", err)
+				}
+			}
+			mg.Finished(member)
+		}(member)
+	}
+	wg.Wait()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-advanced:
+		case <-time.After(time.Second):
+			t.Fatal("Expected both members to advance")
+		}
+	}
+}
+
+func TestParseMirrorTimeout(t *testing.T) {
+	if parseMirrorTimeout("") != DefaultMirrorTimeout || parseMirrorTimeout("soon") != DefaultMirrorTimeout {
+		t.Errorf("Expected the default timeout")
+	}
+	if parseMirrorTimeout("5") != 5*time.Second || parseMirrorTimeout("0") != 0 {
+		t.Errorf("Timeouts not parsed as expected")
+	}
+}
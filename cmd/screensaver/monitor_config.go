@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MonitorConfig overrides what is played on one monitor. Monitors are
+// identified by their device name, e.g. \\.\DISPLAY2, as reported in
+// Monitor.Name. Anything left empty falls back to the defaults.
+type MonitorConfig struct {
+	Sources       []MediaSource `json:"sources,omitempty"`
+	SelectionMode SelectionMode `json:"selectionMode,omitempty"`
+}
+
+// MonitorConfigs maps monitor device names to their configuration.
+type MonitorConfigs map[string]MonitorConfig
+
+// parseMonitorConfigs decodes the MonitorSources registry value.
+func parseMonitorConfigs(value string) (MonitorConfigs, error) {
+	var configs MonitorConfigs
+	if err := json.Unmarshal([]byte(value), &configs); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]string{}
+	for name, config := range configs {
+		// Names are matched without regard to case, so they must differ by
+		// more than that.
+		if other, ok := seen[strings.ToLower(name)]; ok {
+			return nil, fmt.Errorf("monitors %v and %v differ only in case", other, name)
+		}
+		seen[strings.ToLower(name)] = name
+
+		if err := validateMediaSources(config.Sources); err != nil {
+			return nil, fmt.Errorf("monitor %v: %w", name, err)
+		}
+		if len(config.SelectionMode) > 0 {
+			config.SelectionMode = parseSelectionMode(string(config.SelectionMode))
+			configs[name] = config
+		}
+	}
+
+	return configs, nil
+}
+
+// Resolve returns the sources and selection mode for the named monitor. Device
+// names are matched without regard to case.
+func (mc MonitorConfigs) Resolve(name string, sources []MediaSource, mode SelectionMode) ([]MediaSource, SelectionMode) {
+	for configName, config := range mc {
+		if !strings.EqualFold(configName, name) {
+			continue
+		}
+
+		if len(config.Sources) > 0 {
+			sources = config.Sources
+		}
+		if len(config.SelectionMode) > 0 {
+			mode = config.SelectionMode
+		}
+		break
+	}
+
+	return sources, mode
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMonitorConfigs(t *testing.T) {
+	configs, err := parseMonitorConfigs(`{
+		"\\\\.\\DISPLAY2": {"sources": [{"type": "directory", "path": "D:\\Portrait"}], "selectionMode": "least-recent"},
+		"\\\\.\\DISPLAY3": {"selectionMode": "bogus"}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := MonitorConfigs{
+		`\\.\DISPLAY2`: {
+			Sources:       []MediaSource{{Type: DirectorySource, Path: `D:\Portrait`, Weight: DefaultSourceWeight, Enabled: true, Recursive: true}},
+			SelectionMode: LeastRecentSelection,
+		},
+		`\\.\DISPLAY3`: {SelectionMode: ShuffleSelection},
+	}
+	if !reflect.DeepEqual(configs, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, configs)
+	}
+
+	for _, value := range []string{
+		`[]`,
+		`{"\\\\.\\DISPLAY2": {"sources": [{"type": "urls", "weight": -1}]}}`,
+		`{"\\\\.\\DISPLAY2": {}, "\\\\.\\display2": {}}`,
+	} {
+		if _, err := parseMonitorConfigs(value); err == nil {
+			t.Errorf("Expected %s not to parse", value)
+		}
+	}
+}
+
+func TestMonitorConfigsResolve(t *testing.T) {
+	defaults := []MediaSource{{Type: DirectorySource, Path: `C:\Videos`, Weight: 1, Enabled: true}}
+	portrait := []MediaSource{{Type: DirectorySource, Path: `D:\Portrait`, Weight: 1, Enabled: true}}
+
+	configs := MonitorConfigs{
+		`\\.\DISPLAY2`: {Sources: portrait, SelectionMode: LeastRecentSelection},
+		`\\.\DISPLAY3`: {Sources: portrait},
+		`\\.\DISPLAY4`: {SelectionMode: LeastRecentSelection},
+	}
+
+	for _, test := range []struct {
+		name    string
+		sources []MediaSource
+		mode    SelectionMode
+	}{
+		{`\\.\DISPLAY1`, defaults, ShuffleSelection},
+		{`\\.\DISPLAY2`, portrait, LeastRecentSelection},
+		{`\\.\display2`, portrait, LeastRecentSelection},
+		{`\\.\DISPLAY3`, portrait, ShuffleSelection},
+		{`\\.\DISPLAY4`, defaults, LeastRecentSelection},
+		{"", defaults, ShuffleSelection},
+	} {
+		sources, mode := configs.Resolve(test.name, defaults, ShuffleSelection)
+		if !reflect.DeepEqual(sources, test.sources) || mode != test.mode {
+			t.Errorf("Resolve(%q) = %v, %v, expected %v, %v", test.name, sources, mode, test.sources, test.mode)
+		}
+	}
+
+	var none MonitorConfigs
+	if sources, mode := none.Resolve(`\\.\DISPLAY1`, defaults, LeastRecentSelection); !reflect.DeepEqual(sources, defaults) || mode != LeastRecentSelection {
+		t.Errorf("Expected no configs to give the defaults, got %v, %v", sources, mode)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// isNetworkPath reports whether path is on a network share, either by UNC
+// path or a mapped drive. Change notifications from shares are unreliable,
+// so we poll them instead.
+func isNetworkPath(path string) bool {
+	volume := filepath.VolumeName(path)
+	if len(volume) == 0 {
+		return false
+	}
+
+	root, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return false
+	}
+
+	return windows.GetDriveType(root) == windows.DRIVE_REMOTE
+}
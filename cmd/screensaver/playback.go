@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// PlaybackOptions control how much of a clip is played, and how. RandomStart
+// and MaxDuration come from the source; the rest from the clip's sidecars.
+type PlaybackOptions struct {
+	// RandomStart starts the clip at a random point, rather than the
+	// beginning.
+	RandomStart bool
+	// MaxDuration moves on to the next clip after this long, if the clip
+	// hasn't finished by then. Zero means play to the end.
+	MaxDuration time.Duration
+	// In and Out trim the clip. Zero means the start and end respectively.
+	In  time.Duration
+	Out time.Duration
+	// Rate is the playback speed, where zero means normal speed.
+	Rate float64
+	// Rotation turns the picture clockwise by this many degrees.
+	Rotation int
+}
+
+// clipTime converts a length of real time into how far the clip moves on in
+// that time, which differs when it plays faster or slower than normal.
+func (po PlaybackOptions) clipTime(d time.Duration) time.Duration {
+	if po.Rate > 0 {
+		return time.Duration(float64(d) * po.Rate)
+	}
+	return d
+}
+
+// mediaOptions returns the libvlc media options for the parts of po that
+// libvlc can take care of itself.
+func (po PlaybackOptions) mediaOptions() []string {
+	var options []string
+
+	if po.In > 0 {
+		options = append(options, fmt.Sprintf(":start-time=%.3f", po.In.Seconds()))
+	}
+	if po.Out > 0 {
+		options = append(options, fmt.Sprintf(":stop-time=%.3f", po.Out.Seconds()))
+	}
+	if po.Rate > 0 && po.Rate != 1 {
+		options = append(options, fmt.Sprintf(":rate=%g", po.Rate))
+	}
+	if po.Rotation != 0 {
+		options = append(options, ":video-filter=transform", fmt.Sprintf(":transform-type=%d", po.Rotation))
+	}
+
+	return options
+}
+
+// limited reports whether the options need the playback loop to keep an eye
+// on the clip.
+func (po PlaybackOptions) limited() bool {
+	return po.RandomStart || po.MaxDuration > 0
+}
+
+// randomStartGuard is how close to the end of a clip a random start may
+// fall, so that we don't start a clip only to switch away seconds later.
+const randomStartGuard = 30 * time.Second
+
+// randomStartOffset picks where to start a clip of the given length. Where
+// there is a maximum duration, we start early enough to play for all of it.
+// Clips too short to leave room return 0. For trimmed clips, pass the length
+// between the in and out points and add the in point to the result.
+func randomStartOffset(length, maxDuration time.Duration, rng *rand.Rand) time.Duration {
+	tail := randomStartGuard
+	if maxDuration > tail {
+		tail = maxDuration
+	}
+
+	latest := length - tail
+	if latest <= 0 {
+		return 0
+	}
+
+	return time.Duration(rng.Int63n(int64(latest)))
+}
+
+// switchPoint returns how far into the clip to move on to the next one, or
+// zero to play until libvlc tells us the clip has ended. When crossfading,
+// we move on early enough for the fade to finish as the clip does, unless
+// the clip is too short to leave room.
+func (po PlaybackOptions) switchPoint(length, startOffset, crossfade time.Duration) time.Duration {
+	var end time.Duration
+	if po.MaxDuration > 0 {
+		end = startOffset + po.clipTime(po.MaxDuration)
+	}
+
+	if crossfade <= 0 {
+		return end
+	}
+
+	clipEnd := length
+	if po.Out > 0 && (clipEnd <= 0 || po.Out < clipEnd) {
+		clipEnd = po.Out
+	}
+	if clipEnd > 0 && (end == 0 || clipEnd < end) {
+		end = clipEnd
+	}
+
+	if fade := po.clipTime(crossfade); end-fade > startOffset {
+		end -= fade
+	}
+
+	return end
+}
+
+// parseCrossfade reads the crossfade duration setting, in seconds. By default
+// we cut straight from one clip to the next.
+func parseCrossfade(value string) time.Duration {
+	if len(value) == 0 {
+		return 0
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		log.Printf("Invalid crossfade %q, not crossfading", value)
+		return 0
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
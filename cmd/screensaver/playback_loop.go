@@ -0,0 +1,534 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	vlc "github.com/sammydre/golang-video-screensaver/vlcwrap"
+)
+
+// playbackLoop plays clip after clip on a pair of decks: choosing them,
+// switching and crossfading between them, and recovering from failures. It
+// knows nothing of windows, so that it can be tested with fake players.
+type playbackLoop struct {
+	// FallbackClip is played, on repeat, when too many clips in a row have
+	// failed. If it is empty the screen is left black.
+	FallbackClip string
+	// Crossfade is how long to fade from one clip to the next over. Zero
+	// cuts straight to the next clip.
+	Crossfade time.Duration
+	// AspectRatio and CropGeometry, if set, are given to the players to
+	// override the shape of the picture, as when spanning monitors.
+	AspectRatio  string
+	CropGeometry string
+	// ClipFinished, if set, is called when a clip is done with, instead of
+	// moving on to the next. The loop then waits for Advance to be called,
+	// so that several can move on together.
+	ClipFinished func()
+
+	nextMediaFileCallback func(ahead bool) (MediaItem, error)
+	mediaStartedCallback  func(MediaItem)
+	mediaFailedCallback   func(string, error)
+	synchroniseCallback   func(func())
+	// bus delivers the decks' events through synchroniseCallback.
+	bus *vlc.EventBus
+	// playingCallback, if set, is called when a clip has been started.
+	playingCallback func()
+	// newDeck creates a deck to play on, layered if it must be able to fade.
+	newDeck func(layered bool) (*videoDeck, error)
+	// decks are our two players: the active one is on screen, and the other
+	// has the next clip ready, if it could be loaded.
+	decks               [2]*videoDeck
+	active              int
+	fade                *crossfade
+	waiting             bool
+	rng                 *rand.Rand
+	consecutiveFailures int
+	retryTimer          *time.Timer
+	stopChecking        chan struct{}
+}
+
+// crossfade is a switch between decks that is under way.
+type crossfade struct {
+	from, to *videoDeck
+	started  time.Time
+	stop     chan struct{}
+}
+
+// maxConsecutiveFailures is how many clips in a row may fail before we give
+// up and show the fallback.
+const maxConsecutiveFailures = 5
+
+// fallbackRetryDelay is how long the fallback is shown for before we try
+// playing clips again.
+const fallbackRetryDelay = time.Minute
+
+// playbackCheckInterval is how often we check whether the current clip has
+// played for as long as it is allowed to.
+const playbackCheckInterval = 500 * time.Millisecond
+
+// crossfadeStepInterval is how often a crossfade is moved on.
+const crossfadeStepInterval = 40 * time.Millisecond
+
+// ErrPlayback is reported for clips that libvlc could load but not play.
+var ErrPlayback = errors.New("libvlc encountered an error playing the clip")
+
+func newPlaybackLoop(newDeck func(bool) (*videoDeck, error), mediaPathCallback func(bool) (MediaItem, error), mediaStartedCallback func(MediaItem), mediaFailedCallback func(string, error), synchroniseCallback func(func())) *playbackLoop {
+	return &playbackLoop{
+		nextMediaFileCallback: mediaPathCallback,
+		mediaStartedCallback:  mediaStartedCallback,
+		mediaFailedCallback:   mediaFailedCallback,
+		synchroniseCallback:   synchroniseCallback,
+		bus:                   vlc.NewEventBus(vlc.DispatcherFunc(synchroniseCallback)),
+		newDeck:               newDeck,
+		rng:                   rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+// start creates the decks and plays the first clip.
+func (pl *playbackLoop) start() {
+	log.Print("Creating and initialising VLC players...")
+
+	for i := range pl.decks {
+		deck, err := pl.newDeck(pl.Crossfade > 0)
+		if err != nil && pl.Crossfade > 0 {
+			// Layered child windows need Windows 8 or later.
+			log.Printf("Unable to create a window to crossfade with, cutting between clips instead: %v", err)
+			pl.Crossfade = 0
+			deck, err = pl.newDeck(false)
+		}
+		if err != nil {
+			log.Panic(err)
+		}
+		pl.decks[i] = deck
+
+		if err := pl.attachEvents(deck); err != nil {
+			log.Panic(err)
+		}
+
+		if len(pl.AspectRatio) > 0 {
+			if err := deck.player.SetAspectRatio(pl.AspectRatio); err != nil {
+				log.Printf("Unable to set aspect ratio %v: %v", pl.AspectRatio, err)
+			}
+		}
+		if len(pl.CropGeometry) > 0 {
+			if err := deck.player.SetCropGeometry(pl.CropGeometry); err != nil {
+				log.Printf("Unable to crop to %v: %v", pl.CropGeometry, err)
+			}
+		}
+	}
+
+	// Random starts, maximum durations and crossfades need checking on as
+	// the clip plays; libvlc has no event for "this far in".
+	pl.stopChecking = make(chan struct{})
+	go func(ticker *time.Ticker, stop chan struct{}) {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				pl.synchroniseCallback(pl.checkPlayback)
+			case <-stop:
+				return
+			}
+		}
+	}(time.NewTicker(playbackCheckInterval), pl.stopChecking)
+
+	log.Print("VLC players initialised, playing")
+	pl.playNext()
+}
+
+func (pl *playbackLoop) attachEvents(deck *videoDeck) error {
+	events, err := deck.player.Events()
+	if err != nil {
+		return err
+	}
+	// Our callbacks play and stop clips, which they mustn't do from libvlc's
+	// threads, so are called through the bus.
+	deck.events = pl.bus.Source(events)
+
+	endReachedCallback := func(event vlc.Event, payload interface{}, userData interface{}) {
+		// A deck we have already switched away from may finish while fading
+		// out; that's no reason to move on again.
+		if deck != pl.activeDeck() {
+			return
+		}
+		pl.consecutiveFailures = 0
+		pl.finished()
+	}
+
+	deck.endReachedEventId, err = deck.events.Attach(vlc.MediaPlayerEndReached, endReachedCallback, nil)
+	if err != nil {
+		return err
+	}
+
+	encounteredErrorCallback := func(event vlc.Event, payload interface{}, userData interface{}) {
+		err := ErrPlayback
+		if p, ok := payload.(vlc.EncounteredErrorPayload); ok && len(p.Message) > 0 {
+			err = fmt.Errorf("%w: %s", ErrPlayback, p.Message)
+		}
+
+		if pl.fade != nil && deck == pl.fade.from {
+			// It is on its way out anyway.
+			return
+		}
+
+		if len(deck.item.Location) == 0 {
+			if deck == pl.activeDeck() {
+				// The fallback clip failed; leave the screen black.
+				log.Printf("Error playing fallback clip %v", pl.FallbackClip)
+			}
+			return
+		}
+
+		pl.failed(deck.item.Location, err)
+		deck.stop()
+
+		if deck == pl.activeDeck() {
+			pl.finished()
+		} else {
+			pl.prepareNext()
+		}
+	}
+
+	deck.encounteredErrorEventId, err = deck.events.Attach(vlc.MediaPlayerEncounteredError, encounteredErrorCallback, nil)
+	return err
+}
+
+func (pl *playbackLoop) activeDeck() *videoDeck {
+	return pl.decks[pl.active]
+}
+
+func (pl *playbackLoop) standbyDeck() *videoDeck {
+	return pl.decks[1-pl.active]
+}
+
+// finished moves on from the clip that is done with, unless we are to wait
+// to be told to.
+func (pl *playbackLoop) finished() {
+	if pl.ClipFinished == nil {
+		pl.advance()
+		return
+	}
+
+	if !pl.waiting {
+		pl.waiting = true
+		pl.ClipFinished()
+	}
+}
+
+// Advance moves on to the next clip, whether or not the current one is done
+// with. It may be called from any goroutine.
+func (pl *playbackLoop) Advance() {
+	pl.synchroniseCallback(func() {
+		if pl.decks[0] == nil {
+			return
+		}
+		pl.waiting = false
+		pl.consecutiveFailures = 0
+		pl.advance()
+	})
+}
+
+// advance moves on to the next clip: straight away if the standby deck has
+// it ready, otherwise by loading one.
+func (pl *playbackLoop) advance() {
+	pl.endFade()
+
+	if pl.standbyDeck().ready {
+		pl.switchDecks()
+		pl.prepareNext()
+		return
+	}
+
+	pl.playNext()
+}
+
+// playNext plays the next clip, moving on to another if it can't be loaded.
+// After too many failures in a row it shows the fallback instead, rather than
+// spinning through the library.
+func (pl *playbackLoop) playNext() {
+	pl.endFade()
+	deck := pl.standbyDeck()
+
+	for pl.consecutiveFailures < maxConsecutiveFailures {
+		item, err := pl.nextMediaFileCallback(false)
+		if err != nil {
+			log.Printf("Nothing to play: %v", err)
+			break
+		}
+
+		if err := deck.load(item, false); err != nil {
+			pl.failed(item.Location, err)
+			continue
+		}
+
+		pl.switchDecks()
+		pl.prepareNext()
+		if pl.playingCallback != nil {
+			pl.playingCallback()
+		}
+		return
+	}
+
+	pl.showFallback()
+}
+
+// prepareNext loads the next clip into the standby deck, paused, so that it
+// is ready to switch to. While a crossfade is under way, the standby deck is
+// still fading out, so this waits until the crossfade is over.
+func (pl *playbackLoop) prepareNext() {
+	if pl.fade != nil {
+		return
+	}
+	deck := pl.standbyDeck()
+
+	for pl.consecutiveFailures < maxConsecutiveFailures {
+		item, err := pl.nextMediaFileCallback(true)
+		if err != nil {
+			log.Printf("Nothing to prepare: %v", err)
+			return
+		}
+
+		if err := deck.load(item, true); err != nil {
+			pl.failed(item.Location, err)
+			continue
+		}
+
+		return
+	}
+}
+
+// switchDecks puts the standby deck on screen, fading over to it if we
+// crossfade, and stops the other.
+func (pl *playbackLoop) switchDecks() {
+	from, to := pl.activeDeck(), pl.standbyDeck()
+	pl.active = 1 - pl.active
+
+	if to.ready {
+		to.ready = false
+		if err := to.player.SetPause(false); err != nil {
+			log.Printf("Unable to start %v: %v", to.item.Location, err)
+		}
+	}
+	pl.mediaStartedCallback(to.item)
+
+	if pl.Crossfade <= 0 || len(from.item.Location) == 0 {
+		to.show()
+		from.hide()
+		from.stop()
+		return
+	}
+
+	to.setAlpha(0)
+	to.show()
+
+	pl.fade = &crossfade{from: from, to: to, started: time.Now(), stop: make(chan struct{})}
+	go func(ticker *time.Ticker, stop chan struct{}) {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				pl.synchroniseCallback(pl.stepFade)
+			case <-stop:
+				return
+			}
+		}
+	}(time.NewTicker(crossfadeStepInterval), pl.fade.stop)
+}
+
+// stepFade moves a crossfade on. Once it has taken as long as it should, the
+// deck that faded out is free to prepare the next clip.
+func (pl *playbackLoop) stepFade() {
+	if pl.fade == nil {
+		return
+	}
+
+	progress := float64(time.Since(pl.fade.started)) / float64(pl.Crossfade)
+	if progress >= 1 {
+		pl.endFade()
+		pl.prepareNext()
+		return
+	}
+
+	pl.fade.to.setAlpha(byte(progress * 255))
+}
+
+// endFade completes any crossfade under way at once.
+func (pl *playbackLoop) endFade() {
+	if pl.fade == nil {
+		return
+	}
+
+	close(pl.fade.stop)
+	pl.fade.to.setAlpha(255)
+	pl.fade.from.hide()
+	pl.fade.from.stop()
+	pl.fade = nil
+}
+
+// checkPlayback applies the current clip's playback options: seeking to a
+// random start once its length is known, and moving on once it has played
+// for as long as it may, or early enough to crossfade out of it.
+func (pl *playbackLoop) checkPlayback() {
+	if pl.decks[0] == nil {
+		return
+	}
+
+	// A paused clip knows its length once it has been opened, so can be
+	// moved to its random start before it is shown.
+	for _, deck := range pl.decks {
+		if deck.seekPending && (deck == pl.activeDeck() || deck.ready) {
+			if length, err := deck.player.Length(); err == nil && length > 0 {
+				deck.seekPending = false
+				pl.seekToRandomStart(deck, length)
+			}
+		}
+	}
+
+	deck := pl.activeDeck()
+	if len(deck.item.Location) == 0 || deck.ready {
+		return
+	}
+
+	length, _ := deck.player.Length()
+	switchAt := deck.item.Playback.switchPoint(length, deck.startOffset, pl.Crossfade)
+	if switchAt <= 0 {
+		return
+	}
+
+	if played, err := deck.player.Time(); err == nil && played >= switchAt {
+		log.Printf("%v has played to %v, moving on", deck.item.Location, played.Round(time.Second))
+		pl.consecutiveFailures = 0
+		pl.finished()
+	}
+}
+
+// syncMember returns the clip on screen, and its player for playback sync to
+// keep in step with others. It returns false while there is no steady
+// playback to sync: between clips, during a crossfade, or before a random
+// start.
+func (pl *playbackLoop) syncMember() (MediaItem, syncMember, bool) {
+	if pl.decks[0] == nil || pl.fade != nil || pl.waiting {
+		return MediaItem{}, syncMember{}, false
+	}
+
+	deck := pl.activeDeck()
+	if len(deck.item.Location) == 0 || deck.ready || deck.seekPending || !deck.player.IsPlaying() {
+		return MediaItem{}, syncMember{}, false
+	}
+
+	return deck.item, syncMember{Player: deck.player, Rate: deck.item.Playback.Rate}, true
+}
+
+func (pl *playbackLoop) seekToRandomStart(deck *videoDeck, length time.Duration) {
+	if !deck.player.IsSeekable() {
+		log.Printf("%v is not seekable, playing from the start", deck.item.Location)
+		return
+	}
+
+	playback := deck.item.Playback
+
+	// Trimmed clips start somewhere between their in and out points.
+	start, end := playback.In, length
+	if playback.Out > 0 && playback.Out < end {
+		end = playback.Out
+	}
+
+	offset := randomStartOffset(end-start, playback.clipTime(playback.MaxDuration), pl.rng)
+	if offset == 0 {
+		return
+	}
+	offset += start
+
+	log.Printf("Starting %v at %v of %v", deck.item.Location, offset.Round(time.Second), length.Round(time.Second))
+	if err := deck.player.SetTime(offset); err != nil {
+		log.Printf("Unable to seek %v: %v", deck.item.Location, err)
+		return
+	}
+	deck.startOffset = offset
+}
+
+func (pl *playbackLoop) failed(location string, err error) {
+	log.Printf("Failed to play %v: %v", location, err)
+
+	pl.consecutiveFailures++
+	pl.mediaFailedCallback(location, err)
+}
+
+// showFallback plays the fallback clip on repeat, or stops the player if
+// there isn't one, then tries clips again after a while. When waiting to
+// move on with others, it is instead done with the clip until told to.
+func (pl *playbackLoop) showFallback() {
+	log.Printf("Giving up after %d failures, showing fallback for %v", pl.consecutiveFailures, fallbackRetryDelay)
+
+	pl.endFade()
+	pl.standbyDeck().stop()
+
+	deck := pl.activeDeck()
+	deck.stop()
+
+	if len(pl.FallbackClip) > 0 {
+		if media, err := deck.player.Load(pl.FallbackClip, true); err != nil {
+			log.Printf("Unable to load fallback clip %v: %v", pl.FallbackClip, err)
+		} else {
+			deck.replaceMedia(media)
+			media.AddOption(":input-repeat=65535")
+			deck.player.Play()
+		}
+	}
+	deck.show()
+
+	if pl.ClipFinished != nil {
+		pl.waiting = true
+		pl.ClipFinished()
+		return
+	}
+
+	pl.retryTimer = time.AfterFunc(fallbackRetryDelay, func() {
+		pl.synchroniseCallback(func() {
+			if pl.decks[0] == nil {
+				return
+			}
+			pl.consecutiveFailures = 0
+			pl.playNext()
+		})
+	})
+}
+
+func (pl *playbackLoop) Deinit() {
+	pl.bus.Close()
+	if pl.decks[0] == nil {
+		return
+	}
+
+	if pl.retryTimer != nil {
+		pl.retryTimer.Stop()
+	}
+	if pl.stopChecking != nil {
+		close(pl.stopChecking)
+		pl.stopChecking = nil
+	}
+	if pl.fade != nil {
+		close(pl.fade.stop)
+		pl.fade = nil
+	}
+
+	for i, deck := range pl.decks {
+		if deck != nil {
+			deck.release()
+			pl.decks[i] = nil
+		}
+	}
+}
+
+// resize fits the decks to a new size.
+func (pl *playbackLoop) resize(width, height int32) {
+	for _, deck := range pl.decks {
+		if deck != nil {
+			deck.resize(width, height)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sammydre/golang-video-screensaver/vlcwrap/vlcfake"
+)
+
+type fakeDeckWindow struct {
+	shown     bool
+	alpha     byte
+	destroyed bool
+}
+
+func (fdw *fakeDeckWindow) show()                      { fdw.shown = true }
+func (fdw *fakeDeckWindow) hide()                      { fdw.shown = false }
+func (fdw *fakeDeckWindow) setAlpha(alpha byte)        { fdw.alpha = alpha }
+func (fdw *fakeDeckWindow) resize(width, height int32) {}
+func (fdw *fakeDeckWindow) destroy()                   { fdw.destroyed = true }
+
+// loopTest runs a playback loop on fake players. Work the loop synchronises
+// is queued until run is called, as a window's message loop would.
+type loopTest struct {
+	t       *testing.T
+	library *vlcfake.Library
+	loop    *playbackLoop
+
+	// clips are handed out in turn, with their playback options if any.
+	clips    []string
+	playback map[string]PlaybackOptions
+	next     int
+	started  []string
+	failed   []string
+
+	sync.Mutex
+	queue []func()
+}
+
+func newLoopTest(t *testing.T, clips ...string) *loopTest {
+	lt := &loopTest{
+		t:        t,
+		library:  vlcfake.NewLibrary(),
+		clips:    clips,
+		playback: map[string]PlaybackOptions{},
+	}
+	for _, clip := range clips {
+		lt.library.Add(clip, vlcfake.Clip{Length: 10 * time.Second})
+	}
+
+	lt.loop = newPlaybackLoop(
+		func(bool) (*videoDeck, error) {
+			return &videoDeck{player: lt.library.NewPlayer(), window: &fakeDeckWindow{}}, nil
+		},
+		func(bool) (MediaItem, error) {
+			if len(lt.clips) == 0 {
+				return MediaItem{}, errors.New("no clips")
+			}
+			location := lt.clips[lt.next%len(lt.clips)]
+			lt.next++
+			return MediaItem{Location: location, Playback: lt.playback[location]}, nil
+		},
+		func(item MediaItem) {
+			lt.started = append(lt.started, item.Location)
+		},
+		func(location string, err error) {
+			lt.failed = append(lt.failed, location)
+		},
+		func(f func()) {
+			lt.Lock()
+			lt.queue = append(lt.queue, f)
+			lt.Unlock()
+		})
+	t.Cleanup(lt.loop.Deinit)
+
+	return lt
+}
+
+// run does the queued work, including delivering events, and any it queues
+// in turn.
+func (lt *loopTest) run() {
+	for {
+		lt.loop.bus.Flush()
+
+		lt.Lock()
+		queue := lt.queue
+		lt.queue = nil
+		lt.Unlock()
+
+		if len(queue) == 0 {
+			return
+		}
+		for _, f := range queue {
+			f()
+		}
+	}
+}
+
+func (lt *loopTest) deckPlaying(deck *videoDeck) string {
+	media := deck.player.(*vlcfake.Player).Media()
+	if media == nil {
+		return ""
+	}
+	return media.Location
+}
+
+func (lt *loopTest) expect(active, standby string, standbyState vlcfake.State) {
+	lt.t.Helper()
+
+	activeDeck, standbyDeck := lt.loop.activeDeck(), lt.loop.standbyDeck()
+	if got := lt.deckPlaying(activeDeck); got != active || !activeDeck.player.IsPlaying() {
+		lt.t.Errorf("Expected %q playing on the active deck, got %q (%v)", active, got, activeDeck.player.(*vlcfake.Player).State())
+	}
+	if !activeDeck.window.(*fakeDeckWindow).shown {
+		lt.t.Errorf("Expected the active deck to be shown")
+	}
+	if got := lt.deckPlaying(standbyDeck); got != standby || standbyDeck.player.(*vlcfake.Player).State() != standbyState {
+		lt.t.Errorf("Expected %q %v on the standby deck, got %q (%v)", standby, standbyState, got, standbyDeck.player.(*vlcfake.Player).State())
+	}
+}
+
+func TestPlaybackLoopPlaysClipsInTurn(t *testing.T) {
+	lt := newLoopTest(t, "a.mp4", "b.mp4", "c.mp4")
+	lt.loop.start()
+	lt.expect("a.mp4", "b.mp4", vlcfake.Paused)
+
+	lt.library.Advance(10 * time.Second)
+	lt.run()
+	lt.expect("b.mp4", "c.mp4", vlcfake.Paused)
+
+	if len(lt.started) != 2 || lt.started[0] != "a.mp4" || lt.started[1] != "b.mp4" {
+		t.Errorf("Unexpected clips started %v", lt.started)
+	}
+}
+
+func TestPlaybackLoopSkipsClipsThatFailToLoad(t *testing.T) {
+	lt := newLoopTest(t, "a.mp4", "b.mp4", "c.mp4")
+	lt.library.Remove("b.mp4")
+	lt.loop.start()
+
+	lt.expect("a.mp4", "c.mp4", vlcfake.Paused)
+	if len(lt.failed) != 1 || lt.failed[0] != "b.mp4" {
+		t.Errorf("Expected b.mp4 to fail, got %v", lt.failed)
+	}
+}
+
+func TestPlaybackLoopRecoversFromPlaybackErrors(t *testing.T) {
+	lt := newLoopTest(t, "a.mp4", "b.mp4", "c.mp4")
+	lt.loop.start()
+
+	lt.loop.activeDeck().player.(*vlcfake.Player).Fail("corrupt")
+	// Events are only handled once the loop's thread gets to them.
+	if len(lt.failed) != 0 {
+		t.Fatalf("Expected the error to wait for the loop, got %v", lt.failed)
+	}
+	lt.run()
+
+	lt.expect("b.mp4", "c.mp4", vlcfake.Paused)
+	if len(lt.failed) != 1 || lt.failed[0] != "a.mp4" {
+		t.Errorf("Expected a.mp4 to fail, got %v", lt.failed)
+	}
+}
+
+func TestPlaybackLoopShowsFallback(t *testing.T) {
+	lt := newLoopTest(t, "a.mp4", "b.mp4")
+	lt.library.Remove("a.mp4")
+	lt.library.Remove("b.mp4")
+	lt.library.Add("fallback.mp4", vlcfake.Clip{Length: time.Second})
+	lt.loop.FallbackClip = "fallback.mp4"
+	lt.loop.start()
+
+	if len(lt.failed) != maxConsecutiveFailures {
+		t.Errorf("Expected to give up after %d failures, got %v", maxConsecutiveFailures, lt.failed)
+	}
+
+	player := lt.loop.activeDeck().player.(*vlcfake.Player)
+	if media := player.Media(); media == nil || media.Location != "fallback.mp4" || !player.IsPlaying() {
+		t.Fatalf("Expected the fallback to be playing")
+	}
+	if options := player.Media().Options(); len(options) != 1 || options[0] != ":input-repeat=65535" {
+		t.Errorf("Expected the fallback to repeat, got %v", options)
+	}
+	if lt.loop.retryTimer == nil {
+		t.Errorf("Expected a retry to be scheduled")
+	}
+}
+
+func TestPlaybackLoopWaitsToBeAdvanced(t *testing.T) {
+	lt := newLoopTest(t, "a.mp4", "b.mp4", "c.mp4")
+	var finished int
+	lt.loop.ClipFinished = func() {
+		finished++
+	}
+	lt.loop.start()
+
+	lt.library.Advance(10 * time.Second)
+	lt.run()
+	if finished != 1 || !lt.loop.waiting {
+		t.Fatalf("Expected the loop to be waiting, having finished %d clips", finished)
+	}
+	if got := lt.deckPlaying(lt.loop.activeDeck()); got != "a.mp4" {
+		t.Errorf("Expected a.mp4 to stay on screen, got %q", got)
+	}
+
+	lt.loop.Advance()
+	lt.run()
+	lt.expect("b.mp4", "c.mp4", vlcfake.Paused)
+	if lt.loop.waiting {
+		t.Errorf("Expected the loop to have stopped waiting")
+	}
+}
+
+func TestPlaybackLoopMaxDuration(t *testing.T) {
+	lt := newLoopTest(t, "a.mp4", "b.mp4", "c.mp4")
+	lt.playback["a.mp4"] = PlaybackOptions{MaxDuration: 3 * time.Second}
+	lt.loop.start()
+
+	lt.library.Advance(2 * time.Second)
+	lt.loop.checkPlayback()
+	lt.expect("a.mp4", "b.mp4", vlcfake.Paused)
+
+	lt.library.Advance(2 * time.Second)
+	lt.loop.checkPlayback()
+	lt.expect("b.mp4", "c.mp4", vlcfake.Paused)
+}
+
+func TestPlaybackLoopCrossfades(t *testing.T) {
+	lt := newLoopTest(t, "a.mp4", "b.mp4", "c.mp4")
+	lt.loop.Crossfade = time.Millisecond
+	lt.loop.start()
+
+	lt.library.Advance(10 * time.Second)
+	lt.run()
+
+	fade := lt.loop.fade
+	if fade == nil || fade.to.window.(*fakeDeckWindow).alpha != 0 {
+		t.Fatalf("Expected a crossfade to have started")
+	}
+	// The standby deck is busy fading out, so can't prepare the next clip.
+	if got := lt.deckPlaying(lt.loop.standbyDeck()); got != "a.mp4" {
+		t.Errorf("Expected a.mp4 to be fading out, got %q", got)
+	}
+
+	time.Sleep(2 * lt.loop.Crossfade)
+	lt.loop.stepFade()
+	if lt.loop.fade != nil || fade.to.window.(*fakeDeckWindow).alpha != 255 || fade.from.window.(*fakeDeckWindow).shown {
+		t.Errorf("Expected the crossfade to have finished")
+	}
+	lt.expect("b.mp4", "c.mp4", vlcfake.Paused)
+}
+
+func TestPlaybackLoopDeinitReleasesPlayers(t *testing.T) {
+	lt := newLoopTest(t, "a.mp4", "b.mp4")
+	lt.loop.start()
+	media := lt.loop.activeDeck().media.(*vlcfake.Media)
+
+	lt.loop.Deinit()
+	for _, player := range lt.library.Players() {
+		if !player.Released() || player.Attached() != 0 {
+			t.Errorf("Expected players to be released, with no events attached")
+		}
+	}
+	if !media.Released() {
+		t.Errorf("Expected media to be released")
+	}
+
+	// Events arriving late are ignored.
+	lt.loop.Advance()
+	lt.run()
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Players started on the same clip together still drift apart as it plays,
+// as each decodes at its own pace. Playback sync keeps them in step with the
+// first: those a little out are sped up or slowed down until they catch up,
+// and those a long way out are seeked.
+
+const (
+	// DefaultSyncThreshold is how far out of step a player may be before it
+	// is corrected.
+	DefaultSyncThreshold = 50 * time.Millisecond
+	// DefaultSyncSeekThreshold is how far out of step a player must be to be
+	// seeked, rather than nudged.
+	DefaultSyncSeekThreshold = 2 * time.Second
+	// DefaultSyncNudge is how much faster or slower than normal a player is
+	// run while catching up, as a proportion of its normal speed.
+	DefaultSyncNudge = 0.05
+
+	playbackSyncInterval = time.Second
+	// syncSettleTime is how long a seeked player is left alone, as it takes
+	// a while to start playing again from the new time.
+	syncSettleTime  = 3 * time.Second
+	syncLogInterval = time.Minute
+)
+
+// Clock tells the time. Playback sync takes one so that it can be tested
+// without waiting.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// syncPlayer is the part of a player that playback sync needs; vlc.Player
+// provides it.
+type syncPlayer interface {
+	Time() (time.Duration, error)
+	SetTime(time.Duration) error
+	Rate() (float64, error)
+	SetRate(float64) error
+}
+
+// syncMember is a player to keep in step, and the speed its clip is meant
+// to play at, where zero means normal speed.
+type syncMember struct {
+	Player syncPlayer
+	Rate   float64
+}
+
+func (sm syncMember) baseRate() float64 {
+	if sm.Rate > 0 {
+		return sm.Rate
+	}
+	return 1
+}
+
+// syncSource is something that may be playing a clip to keep in step with
+// others; playbackLoop provides it.
+type syncSource interface {
+	syncMember() (MediaItem, syncMember, bool)
+}
+
+// sameClipMembers returns the members of sources showing the same clip as the
+// first of them that is showing one at all, which comes first so that the
+// others follow it.
+func sameClipMembers(sources []syncSource) []syncMember {
+	var item MediaItem
+	var members []syncMember
+	for _, source := range sources {
+		other, member, ok := source.syncMember()
+		if !ok {
+			continue
+		}
+		if members == nil {
+			item = other
+		} else if other.Location != item.Location {
+			continue
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+// driftStats sums up how far out of step players have been.
+type driftStats struct {
+	samples int
+	total   time.Duration
+	max     time.Duration
+	nudges  int
+	seeks   int
+}
+
+func (ds *driftStats) add(drift time.Duration) {
+	if drift < 0 {
+		drift = -drift
+	}
+	ds.samples++
+	ds.total += drift
+	if drift > ds.max {
+		ds.max = drift
+	}
+}
+
+func (ds driftStats) mean() time.Duration {
+	if ds.samples == 0 {
+		return 0
+	}
+	return ds.total / time.Duration(ds.samples)
+}
+
+// playbackSync keeps players in step with the first of them. Step should be
+// called regularly, on the thread the players are used from.
+type playbackSync struct {
+	Clock         Clock
+	Threshold     time.Duration
+	SeekThreshold time.Duration
+	Nudge         float64
+	LogInterval   time.Duration
+
+	// members returns the players to keep in step at the moment. The first
+	// is the one the others follow.
+	members  func() []syncMember
+	settling map[syncPlayer]time.Time
+	stats    driftStats
+	since    time.Time
+}
+
+func newPlaybackSync(members func() []syncMember) *playbackSync {
+	return &playbackSync{
+		Clock:         systemClock{},
+		Threshold:     DefaultSyncThreshold,
+		SeekThreshold: DefaultSyncSeekThreshold,
+		Nudge:         DefaultSyncNudge,
+		LogInterval:   syncLogInterval,
+		members:       members,
+		settling:      map[syncPlayer]time.Time{},
+	}
+}
+
+// parseSyncThreshold reads a threshold in milliseconds, as stored in the
+// registry. Zero turns playback sync off.
+func parseSyncThreshold(value string) time.Duration {
+	if len(value) == 0 {
+		return DefaultSyncThreshold
+	}
+
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms < 0 {
+		log.Printf("Invalid sync threshold %q, using %v", value, DefaultSyncThreshold)
+		return DefaultSyncThreshold
+	}
+
+	return time.Duration(ms) * time.Millisecond
+}
+
+// Run calls Step every interval, through synchronise, until the returned
+// function is called. synchronise mustn't wait for Step to run, as it isn't
+// called again once stop returns.
+func (ps *playbackSync) Run(interval time.Duration, synchronise func(func())) (stop func()) {
+	stopSync := make(chan struct{})
+	stopped := make(chan struct{})
+	go func(ticker *time.Ticker) {
+		defer close(stopped)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				synchronise(ps.Step)
+			case <-stopSync:
+				return
+			}
+		}
+	}(time.NewTicker(interval))
+
+	return func() {
+		close(stopSync)
+		<-stopped
+	}
+}
+
+// syncReading is where a player was at a moment.
+type syncReading struct {
+	valid bool
+	time  time.Duration
+	rate  float64
+	at    time.Time
+}
+
+// Step brings the players back into step, if they have drifted.
+func (ps *playbackSync) Step() {
+	members := ps.members()
+	now := ps.Clock.Now()
+
+	for player, until := range ps.settling {
+		if !now.Before(until) {
+			delete(ps.settling, player)
+		}
+	}
+
+	if len(members) >= 2 {
+		ps.correct(members)
+	}
+
+	if ps.since.IsZero() {
+		ps.since = now
+	} else if now.Sub(ps.since) >= ps.LogInterval {
+		if ps.stats.samples > 0 {
+			log.Printf("Playback sync over %v: mean drift %v, max %v, %d nudges, %d seeks",
+				now.Sub(ps.since).Round(time.Second), ps.stats.mean().Round(time.Millisecond),
+				ps.stats.max.Round(time.Millisecond), ps.stats.nudges, ps.stats.seeks)
+		}
+		ps.stats = driftStats{}
+		ps.since = now
+	}
+}
+
+func (ps *playbackSync) correct(members []syncMember) {
+	readings := make([]syncReading, len(members))
+	for i, member := range members {
+		rate, err := member.Player.Rate()
+		if err != nil || rate <= 0 {
+			rate = member.baseRate()
+		}
+		t, err := member.Player.Time()
+		if err != nil {
+			continue
+		}
+		readings[i] = syncReading{valid: true, time: t, rate: rate, at: ps.Clock.Now()}
+	}
+
+	// The players are asked for their times one after another, so each
+	// reading is brought forward to the same moment.
+	now := ps.Clock.Now()
+	position := func(r syncReading) time.Duration {
+		return r.time + time.Duration(float64(now.Sub(r.at))*r.rate)
+	}
+
+	if !readings[0].valid {
+		return
+	}
+	reference := position(readings[0])
+
+	for i, member := range members[1:] {
+		reading := readings[i+1]
+		if !reading.valid {
+			continue
+		}
+		if _, ok := ps.settling[member.Player]; ok {
+			continue
+		}
+
+		drift := position(reading) - reference
+		ps.stats.add(drift)
+
+		base := member.baseRate()
+		switch {
+		case math.Abs(float64(drift)) >= float64(ps.SeekThreshold):
+			log.Printf("Player %d is %v out of step, seeking to %v", i+1, drift.Round(time.Millisecond), reference.Round(time.Millisecond))
+			if err := member.Player.SetTime(reference); err != nil {
+				log.Printf("Unable to seek player %d: %v", i+1, err)
+				continue
+			}
+			ps.setRate(i+1, member.Player, reading.rate, base)
+			ps.settling[member.Player] = now.Add(syncSettleTime)
+			ps.stats.seeks++
+
+		case math.Abs(float64(drift)) >= float64(ps.Threshold):
+			want := base * (1 + ps.Nudge)
+			if drift > 0 {
+				want = base * (1 - ps.Nudge)
+			}
+			if ps.setRate(i+1, member.Player, reading.rate, want) {
+				ps.stats.nudges++
+			}
+
+		default:
+			ps.setRate(i+1, member.Player, reading.rate, base)
+		}
+	}
+}
+
+// setRate changes a player's speed, if it isn't already at it, reporting
+// whether it did.
+func (ps *playbackSync) setRate(index int, player syncPlayer, current, want float64) bool {
+	// libvlc keeps rates as floats, so they don't come back exactly.
+	if math.Abs(current-want) < 1e-3 {
+		return false
+	}
+
+	if err := player.SetRate(want); err != nil {
+		log.Printf("Unable to change player %d's speed to %g: %v", index, want, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to, or by tick on every
+// reading, as if asking the players for their times took a while.
+type fakeClock struct {
+	now  time.Time
+	tick time.Duration
+}
+
+func (fc *fakeClock) Now() time.Time {
+	now := fc.now
+	fc.now = fc.now.Add(fc.tick)
+	return now
+}
+
+// fakeSyncPlayer plays along with a fake clock. Its speed is how fast it
+// decodes compared to its rate, so that it can be made to drift.
+type fakeSyncPlayer struct {
+	clock *fakeClock
+	time  time.Duration
+	at    time.Time
+	rate  float64
+	speed float64
+	seeks []time.Duration
+}
+
+func newFakeSyncPlayer(clock *fakeClock, at time.Duration, rate float64) *fakeSyncPlayer {
+	return &fakeSyncPlayer{clock: clock, time: at, at: clock.now, rate: rate, speed: 1}
+}
+
+func (fp *fakeSyncPlayer) catchUp() {
+	fp.time += time.Duration(float64(fp.clock.now.Sub(fp.at)) * fp.rate * fp.speed)
+	fp.at = fp.clock.now
+}
+
+func (fp *fakeSyncPlayer) Time() (time.Duration, error) {
+	fp.catchUp()
+	return fp.time, nil
+}
+
+func (fp *fakeSyncPlayer) SetTime(t time.Duration) error {
+	fp.catchUp()
+	fp.time = t
+	fp.seeks = append(fp.seeks, t)
+	return nil
+}
+
+func (fp *fakeSyncPlayer) Rate() (float64, error) {
+	return fp.rate, nil
+}
+
+func (fp *fakeSyncPlayer) SetRate(rate float64) error {
+	fp.catchUp()
+	fp.rate = rate
+	return nil
+}
+
+func newTestPlaybackSync(clock *fakeClock, members ...syncMember) *playbackSync {
+	ps := newPlaybackSync(func() []syncMember { return members })
+	ps.Clock = clock
+	return ps
+}
+
+func sameRate(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPlaybackSyncNudgesDriftingPlayer(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	leader := newFakeSyncPlayer(clock, 10*time.Second, 1)
+	follower := newFakeSyncPlayer(clock, 9800*time.Millisecond, 1)
+	ps := newTestPlaybackSync(clock, syncMember{Player: leader}, syncMember{Player: follower})
+
+	ps.Step()
+	if !sameRate(follower.rate, 1+DefaultSyncNudge) || !sameRate(leader.rate, 1) {
+		t.Fatalf("Expected the follower to be sped up, got rates %v and %v", leader.rate, follower.rate)
+	}
+
+	// At 5% faster, it makes up 200ms in 4s.
+	clock.now = clock.now.Add(4 * time.Second)
+	ps.Step()
+	if !sameRate(follower.rate, 1) {
+		t.Errorf("Expected the follower back at normal speed, got %v", follower.rate)
+	}
+	if len(follower.seeks) != 0 {
+		t.Errorf("Expected no seeks, got %v", follower.seeks)
+	}
+	if ps.stats.nudges != 1 || ps.stats.max != 200*time.Millisecond {
+		t.Errorf("Unexpected stats %+v", ps.stats)
+	}
+}
+
+func TestPlaybackSyncSlowsPlayerAhead(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	leader := newFakeSyncPlayer(clock, 10*time.Second, 2)
+	follower := newFakeSyncPlayer(clock, 10500*time.Millisecond, 2)
+	ps := newTestPlaybackSync(clock, syncMember{Player: leader, Rate: 2}, syncMember{Player: follower, Rate: 2})
+
+	ps.Step()
+	if !sameRate(follower.rate, 2*(1-DefaultSyncNudge)) {
+		t.Errorf("Expected the follower to be slowed from its clip's speed, got %v", follower.rate)
+	}
+}
+
+func TestPlaybackSyncSeeksDistantPlayer(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	leader := newFakeSyncPlayer(clock, 10*time.Second, 1)
+	follower := newFakeSyncPlayer(clock, 15*time.Second, 1+DefaultSyncNudge)
+	ps := newTestPlaybackSync(clock, syncMember{Player: leader}, syncMember{Player: follower})
+
+	ps.Step()
+	if len(follower.seeks) != 1 || follower.seeks[0] != 10*time.Second {
+		t.Fatalf("Expected the follower to be seeked to 10s, got %v", follower.seeks)
+	}
+	if !sameRate(follower.rate, 1) {
+		t.Errorf("Expected the follower back at normal speed, got %v", follower.rate)
+	}
+
+	// It's left to settle, even though it is slow to get going again.
+	follower.speed = 0
+	clock.now = clock.now.Add(time.Second)
+	ps.Step()
+	if len(follower.seeks) != 1 || !sameRate(follower.rate, 1) {
+		t.Errorf("Expected the follower to be left alone while settling")
+	}
+
+	follower.speed = 1
+	clock.now = clock.now.Add(syncSettleTime)
+	ps.Step()
+	if !sameRate(follower.rate, 1+DefaultSyncNudge) {
+		t.Errorf("Expected the follower to be nudged once settled, got %v", follower.rate)
+	}
+}
+
+func TestPlaybackSyncAllowsForReadingTime(t *testing.T) {
+	// Each reading takes 100ms, which isn't drift.
+	clock := &fakeClock{now: time.Unix(1000, 0), tick: 100 * time.Millisecond}
+	leader := newFakeSyncPlayer(clock, 10*time.Second, 1)
+	follower := newFakeSyncPlayer(clock, 10*time.Second, 1)
+	ps := newTestPlaybackSync(clock, syncMember{Player: leader}, syncMember{Player: follower})
+
+	ps.Step()
+	if !sameRate(follower.rate, 1) || ps.stats.max != 0 {
+		t.Errorf("Expected no correction, got rate %v and stats %+v", follower.rate, ps.stats)
+	}
+}
+
+func TestPlaybackSyncResetsStats(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	leader := newFakeSyncPlayer(clock, 10*time.Second, 1)
+	follower := newFakeSyncPlayer(clock, 10*time.Second, 1)
+	ps := newTestPlaybackSync(clock, syncMember{Player: leader}, syncMember{Player: follower})
+
+	ps.Step()
+	if ps.stats.samples != 1 {
+		t.Fatalf("Expected a sample, got %+v", ps.stats)
+	}
+
+	clock.now = clock.now.Add(ps.LogInterval)
+	ps.Step()
+	if ps.stats.samples != 0 {
+		t.Errorf("Expected the stats to be reset once logged, got %+v", ps.stats)
+	}
+}
+
+func TestPlaybackSyncRunStops(t *testing.T) {
+	ps := newTestPlaybackSync(&fakeClock{now: time.Unix(1000, 0)})
+
+	var calls int32
+	synchronise := func(func()) { atomic.AddInt32(&calls, 1) }
+
+	stop := ps.Run(time.Millisecond, synchronise)
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+
+	stopped := atomic.LoadInt32(&calls)
+	time.Sleep(20 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != stopped {
+		t.Errorf("Expected no steps once stopped, got %d more", got-stopped)
+	}
+}
+
+func TestParseSyncThreshold(t *testing.T) {
+	if parseSyncThreshold("") != DefaultSyncThreshold || parseSyncThreshold("-1") != DefaultSyncThreshold {
+		t.Errorf("Expected the default threshold")
+	}
+	if parseSyncThreshold("100") != 100*time.Millisecond || parseSyncThreshold("0") != 0 {
+		t.Errorf("Thresholds not parsed as expected")
+	}
+}
+
+// fakeSyncSource shows item with player, or nothing if player is nil.
+type fakeSyncSource struct {
+	item   MediaItem
+	player syncPlayer
+}
+
+func (fs fakeSyncSource) syncMember() (MediaItem, syncMember, bool) {
+	if fs.player == nil {
+		return MediaItem{}, syncMember{}, false
+	}
+	return fs.item, syncMember{Player: fs.player}, true
+}
+
+func TestSameClipMembers(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	a := newFakeSyncPlayer(clock, 0, 1)
+	b := newFakeSyncPlayer(clock, 0, 1)
+	c := newFakeSyncPlayer(clock, 0, 1)
+	clip := MediaItem{Location: "clip.mp4"}
+
+	// The first window is between clips, so the second leads.
+	members := sameClipMembers([]syncSource{
+		fakeSyncSource{},
+		fakeSyncSource{item: clip, player: a},
+		fakeSyncSource{item: MediaItem{Location: "other.mp4"}, player: b},
+		fakeSyncSource{item: clip, player: c},
+	})
+	if len(members) != 2 || members[0].Player != a || members[1].Player != c {
+		t.Errorf("Expected the second and fourth players, led by the second, got %v", members)
+	}
+
+	if members := sameClipMembers([]syncSource{fakeSyncSource{}, fakeSyncSource{}}); len(members) != 0 {
+		t.Errorf("Expected no members while nothing is playing, got %v", members)
+	}
+}
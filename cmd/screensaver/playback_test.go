@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestRandomStartOffset(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 1000; i++ {
+		// A two hour recording, to be shown for ten minutes at most
+		offset := randomStartOffset(2*time.Hour, 10*time.Minute, rng)
+		if offset < 0 || offset > 110*time.Minute {
+			t.Fatalf("Offset %v leaves less than ten minutes to play", offset)
+		}
+
+		// No maximum: only the guard band is left at the end
+		offset = randomStartOffset(time.Hour, 0, rng)
+		if offset < 0 || offset > time.Hour-randomStartGuard {
+			t.Fatalf("Offset %v is within the guard band", offset)
+		}
+	}
+
+	if offset := randomStartOffset(20*time.Second, 0, rng); offset != 0 {
+		t.Errorf("Expected a short clip to start at the beginning, got %v", offset)
+	}
+	if offset := randomStartOffset(5*time.Minute, 10*time.Minute, rng); offset != 0 {
+		t.Errorf("Expected a clip shorter than the maximum to start at the beginning, got %v", offset)
+	}
+}
+
+func TestMediaSelectorPlayback(t *testing.T) {
+	sources := []MediaSource{
+		{Type: URLListSource, URLs: []string{"http://talks/1"}, Weight: 1, Enabled: true, RandomStart: true, MaxMinutes: 2.5},
+	}
+
+	ms := NewMediaSelector(sources, DefaultMediaExtensions, ShuffleSelection, nil, 1)
+	item, err := ms.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := PlaybackOptions{RandomStart: true, MaxDuration: 150 * time.Second}
+	if item.Playback != expected {
+		t.Errorf("Expected playback %+v, got %+v", expected, item.Playback)
+	}
+}
+
+func TestSwitchPoint(t *testing.T) {
+	cases := []struct {
+		playback    PlaybackOptions
+		length      time.Duration
+		startOffset time.Duration
+		crossfade   time.Duration
+		expected    time.Duration
+	}{
+		// Without a crossfade or maximum, libvlc tells us when to move on
+		{PlaybackOptions{}, time.Minute, 0, 0, 0},
+		{PlaybackOptions{MaxDuration: time.Minute}, time.Hour, 10 * time.Minute, 0, 11 * time.Minute},
+		// Crossfades finish as the clip ends
+		{PlaybackOptions{}, time.Minute, 0, 2 * time.Second, 58 * time.Second},
+		{PlaybackOptions{Out: 30 * time.Second}, time.Minute, 0, 2 * time.Second, 28 * time.Second},
+		{PlaybackOptions{MaxDuration: time.Minute}, time.Hour, 0, 2 * time.Second, 58 * time.Second},
+		// Playing at double speed, the fade covers twice as much of the clip
+		{PlaybackOptions{Rate: 2}, time.Minute, 0, 2 * time.Second, 56 * time.Second},
+		// Too short to fade out of early
+		{PlaybackOptions{}, time.Second, 0, 2 * time.Second, time.Second},
+		// Length not known yet
+		{PlaybackOptions{}, 0, 0, 2 * time.Second, 0},
+	}
+
+	for _, c := range cases {
+		if point := c.playback.switchPoint(c.length, c.startOffset, c.crossfade); point != c.expected {
+			t.Errorf("%+v of %v from %v with %v crossfade: expected %v, got %v",
+				c.playback, c.length, c.startOffset, c.crossfade, c.expected, point)
+		}
+	}
+}
+
+func TestParseCrossfade(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":     0,
+		"0":    0,
+		"1.5":  1500 * time.Millisecond,
+		"-2":   0,
+		"slow": 0,
+	}
+
+	for value, expected := range cases {
+		if crossfade := parseCrossfade(value); crossfade != expected {
+			t.Errorf("Expected %v for %q, got %v", expected, value, crossfade)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math/rand"
+)
+
+// Playlist deals items from a shuffled deck. Every item is played once before
+// the deck is refilled, and the last NoRepeat items played are kept away from
+// the top of a freshly shuffled deck so they don't come straight back round.
+//
+// A Playlist is deterministic for a given seed and sequence of calls, and is
+// not safe for concurrent use.
+type Playlist struct {
+	NoRepeat int
+
+	rng    *rand.Rand
+	items  []string
+	deck   []string
+	recent []string
+}
+
+// DefaultPlaylistNoRepeat is how many recently played items are kept out of
+// the start of each new deck.
+const DefaultPlaylistNoRepeat = 3
+
+func NewPlaylist(seed int64, noRepeat int) *Playlist {
+	return &Playlist{
+		NoRepeat: noRepeat,
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// SetItems replaces the set of items the playlist deals from. Items still
+// waiting in the current deck are kept in their shuffled order; items that
+// have gone away are dropped, and new items are shuffled into what remains.
+func (p *Playlist) SetItems(items []string) {
+	present := make(map[string]bool, len(items))
+	for _, item := range items {
+		present[item] = true
+	}
+
+	known := make(map[string]bool, len(p.items))
+	for _, item := range p.items {
+		known[item] = true
+	}
+
+	var deck []string
+	for _, item := range p.deck {
+		if present[item] {
+			deck = append(deck, item)
+		}
+	}
+
+	for _, item := range items {
+		if known[item] {
+			continue
+		}
+		// Insert at a random position at or after the top of the deck.
+		index := p.rng.Intn(len(deck) + 1)
+		deck = append(deck, "")
+		copy(deck[index+1:], deck[index:])
+		deck[index] = item
+	}
+
+	p.items = append(p.items[:0], items...)
+	p.deck = deck
+}
+
+// Len returns the number of items the playlist deals from.
+func (p *Playlist) Len() int {
+	return len(p.items)
+}
+
+// Next deals the next item, refilling the deck first if it is empty. It
+// returns false if the playlist has no items.
+func (p *Playlist) Next() (string, bool) {
+	if len(p.items) == 0 {
+		return "", false
+	}
+
+	if len(p.deck) == 0 {
+		p.refill()
+	}
+
+	item := p.deck[len(p.deck)-1]
+	p.deck = p.deck[:len(p.deck)-1]
+
+	p.recent = append(p.recent, item)
+	if len(p.recent) > p.NoRepeat {
+		p.recent = p.recent[len(p.recent)-p.NoRepeat:]
+	}
+
+	return item, true
+}
+
+// refill shuffles every item into a new deck. The deck is dealt from the end
+// of the slice, so recently played items are moved towards the front.
+func (p *Playlist) refill() {
+	p.deck = append(p.deck[:0], p.items...)
+	p.rng.Shuffle(len(p.deck), func(i, j int) {
+		p.deck[i], p.deck[j] = p.deck[j], p.deck[i]
+	})
+
+	// With fewer items than the no-repeat window, the best we can do is keep
+	// the most recent len-1 items away from the top.
+	window := p.NoRepeat
+	if window > len(p.deck)-1 {
+		window = len(p.deck) - 1
+	}
+	if window <= 0 {
+		return
+	}
+
+	recent := make(map[string]bool, window)
+	var front []string
+	for i := len(p.recent) - 1; i >= 0 && len(front) < window; i-- {
+		item := p.recent[i]
+		if recent[item] {
+			continue
+		}
+		for _, candidate := range p.deck {
+			if candidate == item {
+				recent[item] = true
+				front = append(front, item)
+				break
+			}
+		}
+	}
+
+	// Most recently played goes at the very front so it is dealt last, then
+	// the rest of the recent items, then everything else in shuffled order.
+	for _, item := range p.deck {
+		if !recent[item] {
+			front = append(front, item)
+		}
+	}
+	p.deck = front
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func makeItems(n int) []string {
+	var ret []string
+	for i := 0; i < n; i++ {
+		ret = append(ret, fmt.Sprintf("clip%02d.mp4", i))
+	}
+	return ret
+}
+
+func TestPlaylistDealsEveryItemOncePerDeck(t *testing.T) {
+	items := makeItems(7)
+	p := NewPlaylist(1, DefaultPlaylistNoRepeat)
+	p.SetItems(items)
+
+	for round := 0; round < 5; round++ {
+		seen := map[string]bool{}
+		for i := 0; i < len(items); i++ {
+			item, ok := p.Next()
+			if !ok {
+				t.Fatal("Next returned false with items present")
+			}
+			if seen[item] {
+				t.Fatalf("round %d: %s dealt twice", round, item)
+			}
+			seen[item] = true
+		}
+	}
+}
+
+func TestPlaylistNoRepeatAcrossRefill(t *testing.T) {
+	const noRepeat = 3
+
+	for seed := int64(0); seed < 200; seed++ {
+		p := NewPlaylist(seed, noRepeat)
+		p.SetItems(makeItems(8))
+
+		var history []string
+		for i := 0; i < 8*6; i++ {
+			item, _ := p.Next()
+			for back := 1; back <= noRepeat && back <= len(history); back++ {
+				if history[len(history)-back] == item {
+					t.Fatalf("seed %d: %s repeated after %d items", seed, item, back)
+				}
+			}
+			history = append(history, item)
+		}
+	}
+}
+
+func TestPlaylistIsDeterministic(t *testing.T) {
+	a := NewPlaylist(42, DefaultPlaylistNoRepeat)
+	b := NewPlaylist(42, DefaultPlaylistNoRepeat)
+	a.SetItems(makeItems(10))
+	b.SetItems(makeItems(10))
+
+	for i := 0; i < 30; i++ {
+		x, _ := a.Next()
+		y, _ := b.Next()
+		if x != y {
+			t.Fatalf("deal %d differs: %s vs %s", i, x, y)
+		}
+	}
+}
+
+func TestPlaylistSetItems(t *testing.T) {
+	p := NewPlaylist(7, DefaultPlaylistNoRepeat)
+
+	if _, ok := p.Next(); ok {
+		t.Error("Empty playlist dealt an item")
+	}
+
+	p.SetItems([]string{"a", "b", "c"})
+	first, _ := p.Next()
+
+	// Remove whatever is left and add something new; only the new item can
+	// come next.
+	p.SetItems([]string{first, "d"})
+	if item, _ := p.Next(); item != "d" {
+		t.Errorf("Expected the newly added item, got %s", item)
+	}
+
+	// A single item can always be repeated.
+	p.SetItems([]string{"only"})
+	for i := 0; i < 3; i++ {
+		if item, ok := p.Next(); !ok || item != "only" {
+			t.Errorf("Expected the only item, got %s %v", item, ok)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPlaylistDepth limits how deeply playlists may include other playlists.
+const maxPlaylistDepth = 8
+
+var playlistExtensions = []string{".m3u", ".m3u8", ".pls", ".xspf"}
+
+func isPlaylistFile(path string) bool {
+	return !isMediaURL(path) && hasMediaExtension(path, playlistExtensions)
+}
+
+var urlSchemeRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]+://`)
+
+// isMediaURL reports whether location is a URL (http://, rtsp://, file://
+// and so on) rather than a path. Drive letters are a single character, so
+// "C:\" can't be mistaken for a scheme.
+func isMediaURL(location string) bool {
+	return urlSchemeRegexp.MatchString(location)
+}
+
+// fileURLToPath converts a file:// URL into a local or UNC path.
+func fileURLToPath(location string) (string, bool) {
+	u, err := url.Parse(location)
+	if err != nil || !strings.EqualFold(u.Scheme, "file") {
+		return "", false
+	}
+
+	path := u.Path
+	if len(u.Host) > 0 && !strings.EqualFold(u.Host, "localhost") {
+		// file://server/share/clip.mp4
+		return filepath.FromSlash("//" + u.Host + path), true
+	}
+
+	// file:///C:/Videos/clip.mp4 has a path of /C:/Videos/clip.mp4
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+
+	return filepath.FromSlash(path), true
+}
+
+// resolvePlaylistEntry turns an entry as written in a playlist into something
+// we can play: URLs are kept as they are, file:// URLs and relative paths are
+// made into paths relative to the playlist's directory.
+func resolvePlaylistEntry(entry string, dir string) string {
+	entry = strings.TrimSpace(entry)
+
+	if path, ok := fileURLToPath(entry); ok {
+		entry = path
+	} else if isMediaURL(entry) {
+		return entry
+	}
+
+	entry = filepath.FromSlash(entry)
+	if !filepath.IsAbs(entry) && !strings.HasPrefix(entry, `\`) {
+		entry = filepath.Join(dir, entry)
+	}
+
+	return filepath.Clean(entry)
+}
+
+// loadPlaylistFile reads an M3U, M3U8, PLS or XSPF playlist and returns its
+// entries in order. Playlists included from the playlist are expanded in
+// place, and local files which don't exist are skipped.
+func loadPlaylistFile(path string) ([]MediaItem, error) {
+	return loadPlaylistFileNested(path, map[string]bool{}, 0)
+}
+
+func loadPlaylistFileNested(path string, visiting map[string]bool, depth int) ([]MediaItem, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := strings.ToLower(absPath)
+	if visiting[key] {
+		return nil, fmt.Errorf("%v: playlist includes itself", path)
+	}
+	if depth > maxPlaylistDepth {
+		return nil, fmt.Errorf("%v: playlists nested too deeply", path)
+	}
+
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []MediaItem
+	switch strings.ToLower(filepath.Ext(absPath)) {
+	case ".pls":
+		entries, err = parsePLS(data)
+	case ".xspf":
+		entries, err = parseXSPF(data)
+	default:
+		entries, err = parseM3U(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	dir := filepath.Dir(absPath)
+
+	var ret []MediaItem
+	for _, entry := range entries {
+		entry.Location = resolvePlaylistEntry(entry.Location, dir)
+
+		if isMediaURL(entry.Location) {
+			ret = append(ret, entry)
+			continue
+		}
+
+		if isPlaylistFile(entry.Location) {
+			nested, err := loadPlaylistFileNested(entry.Location, visiting, depth+1)
+			if err != nil {
+				log.Printf("Skipping nested playlist: %v", err)
+				continue
+			}
+			ret = append(ret, nested...)
+			continue
+		}
+
+		if info, err := os.Stat(entry.Location); err != nil || !info.Mode().IsRegular() {
+			log.Printf("%v: skipping missing entry %v", path, entry.Location)
+			continue
+		}
+
+		ret = append(ret, entry)
+	}
+
+	return ret, nil
+}
+
+// playlistRecheckInterval is how often a playlist file is looked at again,
+// in the background, to see whether it has changed.
+const playlistRecheckInterval = time.Minute
+
+// cachedPlaylist is a playlist file's entries as they were when last read.
+type cachedPlaylist struct {
+	modTime  time.Time
+	items    []MediaItem
+	err      error
+	checked  time.Time
+	checking bool
+}
+
+func readCachedPlaylist(path string) *cachedPlaylist {
+	cp := &cachedPlaylist{checked: time.Now()}
+	if info, err := os.Stat(path); err == nil {
+		cp.modTime = info.ModTime()
+	}
+	cp.items, cp.err = loadPlaylistFile(path)
+	return cp
+}
+
+// playlistCache holds the entries of every playlist source, so that they
+// aren't read again for every clip. A playlist is read when it is first
+// wanted, then reread in the background whenever its file changes.
+type playlistCache struct {
+	sync.Mutex
+
+	playlists map[string]*cachedPlaylist
+}
+
+var mediaPlaylists = &playlistCache{playlists: map[string]*cachedPlaylist{}}
+
+// Items returns the entries of the playlist at path, as they were when it
+// was last read.
+func (pc *playlistCache) Items(path string) ([]MediaItem, error) {
+	pc.Lock()
+	cached, ok := pc.playlists[path]
+	if ok {
+		if !cached.checking && time.Since(cached.checked) > playlistRecheckInterval {
+			cached.checking = true
+			go pc.recheck(path, cached.modTime)
+		}
+		items, err := cached.items, cached.err
+		pc.Unlock()
+		return items, err
+	}
+	pc.Unlock()
+
+	cached = readCachedPlaylist(path)
+
+	pc.Lock()
+	pc.playlists[path] = cached
+	pc.Unlock()
+
+	return cached.items, cached.err
+}
+
+// recheck rereads the playlist at path if its file has changed since
+// modTime, along with the sidecars of its clips. While it can't be reached,
+// such as when its share is offline, the entries we have are kept.
+func (pc *playlistCache) recheck(path string, modTime time.Time) {
+	var updated *cachedPlaylist
+	if info, err := os.Stat(path); err == nil && !info.ModTime().Equal(modTime) {
+		log.Printf("Playlist %v has changed, rereading it", path)
+		updated = readCachedPlaylist(path)
+	}
+
+	pc.Lock()
+	var items []MediaItem
+	if updated != nil {
+		pc.playlists[path] = updated
+		items = updated.items
+	} else if cached, ok := pc.playlists[path]; ok {
+		cached.checking = false
+		cached.checked = time.Now()
+		items = cached.items
+	}
+	pc.Unlock()
+
+	// The sidecars of the clips listed are reread here too, as no index
+	// scan covers them.
+	dirs := map[string]bool{}
+	for _, item := range items {
+		if !isMediaURL(item.Location) {
+			dirs[filepath.Dir(item.Location)] = true
+		}
+	}
+	for dir := range dirs {
+		mediaSidecars.Reload(dir)
+	}
+}
+
+// parseSeconds parses a playlist duration in (possibly fractional) seconds.
+// Negative values mean unknown, as for live streams.
+func parseSeconds(value string) time.Duration {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func playlistLines(data []byte) *bufio.Scanner {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	return bufio.NewScanner(bytes.NewReader(data))
+}
+
+// parseM3U parses both plain and extended M3U. For the latter, an #EXTINF
+// line gives the duration and title of the entry that follows it:
+//
+//	#EXTINF:123,Artist - Title
+//	#EXTINF:-1 tvg-id="x",Stream title
+func parseM3U(data []byte) ([]MediaItem, error) {
+	var ret []MediaItem
+	var pending MediaItem
+
+	scanner := playlistLines(data)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if len(line) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			if info := strings.TrimPrefix(line, "#EXTINF:"); info != line {
+				var duration string
+				if comma := strings.Index(info, ","); comma >= 0 {
+					duration, pending.Title = info[:comma], strings.TrimSpace(info[comma+1:])
+				} else {
+					duration = info
+				}
+				// Attributes may follow the duration, separated by spaces.
+				if space := strings.IndexByte(duration, ' '); space >= 0 {
+					duration = duration[:space]
+				}
+				pending.Duration = parseSeconds(duration)
+			}
+			continue
+		}
+
+		pending.Location = line
+		ret = append(ret, pending)
+		pending = MediaItem{}
+	}
+
+	return ret, scanner.Err()
+}
+
+// parsePLS parses the INI-like PLS format:
+//
+//	[playlist]
+//	File1=clip.mp4
+//	Title1=Clip
+//	Length1=30
+func parsePLS(data []byte) ([]MediaItem, error) {
+	entries := map[int]*MediaItem{}
+	sawHeader := false
+
+	scanner := playlistLines(data)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.EqualFold(line, "[playlist]") {
+			sawHeader = true
+			continue
+		}
+
+		equals := strings.Index(line, "=")
+		if equals < 0 {
+			continue
+		}
+
+		key, value := strings.ToLower(line[:equals]), strings.TrimSpace(line[equals+1:])
+
+		var field string
+		for _, prefix := range []string{"file", "title", "length"} {
+			if strings.HasPrefix(key, prefix) {
+				field = prefix
+				break
+			}
+		}
+		if len(field) == 0 {
+			continue
+		}
+
+		index, err := strconv.Atoi(key[len(field):])
+		if err != nil {
+			continue
+		}
+
+		entry, ok := entries[index]
+		if !ok {
+			entry = &MediaItem{}
+			entries[index] = entry
+		}
+
+		switch field {
+		case "file":
+			entry.Location = value
+		case "title":
+			entry.Title = value
+		case "length":
+			entry.Duration = parseSeconds(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !sawHeader {
+		return nil, fmt.Errorf("missing [playlist] section")
+	}
+
+	var indices []int
+	for index, entry := range entries {
+		if len(entry.Location) > 0 {
+			indices = append(indices, index)
+		}
+	}
+	sort.Ints(indices)
+
+	var ret []MediaItem
+	for _, index := range indices {
+		ret = append(ret, *entries[index])
+	}
+
+	return ret, nil
+}
+
+type xspfPlaylist struct {
+	Tracks []struct {
+		Locations []string `xml:"location"`
+		Title     string   `xml:"title"`
+		Duration  int64    `xml:"duration"`
+	} `xml:"trackList>track"`
+}
+
+// parseXSPF parses an XML Shareable Playlist. Locations are URIs, and
+// durations are in milliseconds. Where a track has several locations, we use
+// the first.
+func parseXSPF(data []byte) ([]MediaItem, error) {
+	var playlist xspfPlaylist
+	if err := xml.Unmarshal(data, &playlist); err != nil {
+		return nil, err
+	}
+
+	var ret []MediaItem
+	for _, track := range playlist.Tracks {
+		if len(track.Locations) == 0 {
+			continue
+		}
+
+		location := strings.TrimSpace(track.Locations[0])
+		if !isMediaURL(location) {
+			// Relative URI references are percent-encoded.
+			if unescaped, err := url.PathUnescape(location); err == nil {
+				location = unescaped
+			}
+		}
+
+		ret = append(ret, MediaItem{
+			Location: location,
+			Title:    strings.TrimSpace(track.Title),
+			Duration: time.Duration(track.Duration) * time.Millisecond,
+		})
+	}
+
+	return ret, nil
+}
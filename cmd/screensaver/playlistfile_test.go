@@ -0,0 +1,178 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, path string, contents string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "file://" + path
+}
+
+func TestIsMediaURL(t *testing.T) {
+	for location, expected := range map[string]bool{
+		"http://example.com/a.mp4": true,
+		"rtsp://camera/stream":     true,
+		"file:///C:/Videos/a.mp4":  true,
+		`C:\Videos\a.mp4`:          false,
+		`\\server\share\a.mp4`:     false,
+		"a.mp4":                    false,
+	} {
+		if isMediaURL(location) != expected {
+			t.Errorf("isMediaURL(%q) != %v", location, expected)
+		}
+	}
+}
+
+func TestLoadM3UPlaylist(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "clips", "a.mp4"), "")
+	writeTestFile(t, filepath.Join(dir, "b.mkv"), "")
+	writeTestFile(t, filepath.Join(dir, "nested", "c.mp4"), "")
+	writeTestFile(t, filepath.Join(dir, "nested", "inner.m3u"), "c.mp4\n../main.m3u8\n")
+
+	writeTestFile(t, filepath.Join(dir, "main.m3u8"), "\xef\xbb\xbf#EXTM3U\n"+
+		"#EXTINF:12.5,First clip\n"+
+		"clips/a.mp4\n"+
+		"\n"+
+		"#EXTINF:-1 tvg-id=\"x\",Live stream\n"+
+		"http://example.com/live\n"+
+		fileURL(filepath.Join(dir, "b.mkv"))+"\n"+
+		"missing.mp4\n"+
+		"nested/inner.m3u\n")
+
+	items, err := loadPlaylistFile(filepath.Join(dir, "main.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The nested playlist's reference back to main.m3u8 is a cycle, so is
+	// skipped rather than looping forever.
+	var expected = []MediaItem{
+		{Location: filepath.Join(dir, "clips", "a.mp4"), Title: "First clip", Duration: 12500 * time.Millisecond},
+		{Location: "http://example.com/live", Title: "Live stream"},
+		{Location: filepath.Join(dir, "b.mkv")},
+		{Location: filepath.Join(dir, "nested", "c.mp4")},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Got %v, expected %v", items, expected)
+	}
+}
+
+func TestLoadPLSPlaylist(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.mp4"), "")
+	writeTestFile(t, filepath.Join(dir, "list.pls"), "[playlist]\n"+
+		"File2=https://example.com/b.mp4\n"+
+		"Title2=Second\n"+
+		"File1=a.mp4\n"+
+		"Title1=First\n"+
+		"Length1=30\n"+
+		"NumberOfEntries=2\n"+
+		"Version=2\n")
+
+	items, err := loadPlaylistFile(filepath.Join(dir, "list.pls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = []MediaItem{
+		{Location: filepath.Join(dir, "a.mp4"), Title: "First", Duration: 30 * time.Second},
+		{Location: "https://example.com/b.mp4", Title: "Second"},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Got %v, expected %v", items, expected)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "bad.pls"), "File1=a.mp4\n")
+	if _, err := loadPlaylistFile(filepath.Join(dir, "bad.pls")); err == nil {
+		t.Error("PLS without a [playlist] section parsed")
+	}
+}
+
+func TestLoadXSPFPlaylist(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "with space.mp4"), "")
+	writeTestFile(t, filepath.Join(dir, "list.xspf"), `<?xml version="1.0" encoding="UTF-8"?>
+<playlist xmlns="http://xspf.org/ns/0/" version="1">
+  <trackList>
+    <track>
+      <location>with%20space.mp4</location>
+      <title>Spaced</title>
+      <duration>4000</duration>
+    </track>
+    <track>
+      <location>`+fileURL(filepath.Join(dir, "with space.mp4"))+`</location>
+    </track>
+    <track>
+      <title>No location</title>
+    </track>
+  </trackList>
+</playlist>`)
+
+	items, err := loadPlaylistFile(filepath.Join(dir, "list.xspf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = []MediaItem{
+		{Location: filepath.Join(dir, "with space.mp4"), Title: "Spaced", Duration: 4 * time.Second},
+		{Location: filepath.Join(dir, "with space.mp4")},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Got %v, expected %v", items, expected)
+	}
+}
+
+func TestPlaylistCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.m3u")
+	writeTestFile(t, path, "http://a\n")
+
+	pc := &playlistCache{playlists: map[string]*cachedPlaylist{}}
+	if items, err := pc.Items(path); err != nil || len(items) != 1 {
+		t.Fatalf("Expected one item, got %v, %v", items, err)
+	}
+
+	// Changes aren't seen until the playlist is rechecked.
+	writeTestFile(t, path, "http://a\nhttp://b\n")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := pc.Items(path); len(items) != 1 {
+		t.Errorf("Expected the cached item, got %v", items)
+	}
+
+	modTime := pc.playlists[path].modTime
+	pc.recheck(path, modTime)
+	if items, _ := pc.Items(path); len(items) != 2 {
+		t.Errorf("Expected the changed playlist to be reread, got %v", items)
+	}
+
+	// Rechecking an unchanged playlist keeps what we have.
+	cached := pc.playlists[path]
+	pc.recheck(path, cached.modTime)
+	if pc.playlists[path] != cached {
+		t.Errorf("Expected an unchanged playlist not to be reread")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"time"
+
+	vlc "github.com/sammydre/golang-video-screensaver/vlcwrap"
+)
+
+// probeTimeout is how long we let libvlc spend examining a clip.
+const probeTimeout = 5 * time.Second
+
+// probeMedia asks libvlc for the duration, display size and codec of a
+// local file. It is the ProbeFunc for the media index.
+func probeMedia(path string) (IndexEntry, error) {
+	var ret IndexEntry
+
+	media, err := vlc.NewMediaFromPath(path)
+	if err != nil {
+		return ret, err
+	}
+	defer media.Release()
+
+	if err := media.Parse(probeTimeout); err != nil {
+		return ret, err
+	}
+
+	if ret.Duration, err = media.Duration(); err != nil {
+		return ret, err
+	}
+
+	width, height, err := media.VideoSize()
+	if err != nil {
+		return ret, err
+	}
+	ret.Width, ret.Height = int(width), int(height)
+
+	if ret.Codec, err = media.VideoCodec(); err != nil {
+		return ret, err
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// quarantineExpiry is how long a clip that failed to play is left out for.
+// Failures can be temporary, so clips are given another chance eventually.
+const quarantineExpiry = 7 * 24 * time.Hour
+
+// quarantineThreshold is how many times libvlc must fail to play a local
+// clip before it is left out. Clips that can't be found or opened, such as
+// when a share is offline, aren't counted at all.
+const quarantineThreshold = 3
+
+// URLs are only left out for a while after each failure, as streams are
+// often down for a short time. The delay doubles with each failure, up to
+// maxURLRetryDelay.
+const (
+	urlRetryDelay    = time.Minute
+	maxURLRetryDelay = time.Hour
+)
+
+// quarantineRecheckInterval is how often a quarantined clip's file is looked
+// at to see whether it has been replaced.
+const quarantineRecheckInterval = time.Minute
+
+var ErrAllQuarantined = errors.New("every item is quarantined")
+
+// QuarantineEntry records why a clip is being left out. For local clips,
+// it also records the file as it was, so that the entry can be dropped once
+// the file is replaced.
+type QuarantineEntry struct {
+	Reason     string    `json:"reason"`
+	Failures   int       `json:"failures"`
+	LastFailed time.Time `json:"lastFailed"`
+	Size       int64     `json:"size,omitempty"`
+	ModTime    time.Time `json:"modTime,omitempty"`
+
+	checked time.Time
+}
+
+// excludes reports whether the entry leaves item out at the moment.
+func (qe *QuarantineEntry) excludes(item string, now time.Time) bool {
+	if !isMediaURL(item) {
+		return qe.Failures >= quarantineThreshold
+	}
+
+	delay := urlRetryDelay
+	for i := 1; i < qe.Failures && delay < maxURLRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxURLRetryDelay {
+		delay = maxURLRetryDelay
+	}
+	return now.Before(qe.LastFailed.Add(delay))
+}
+
+func (qe *QuarantineEntry) sameFile(info fs.FileInfo) bool {
+	return qe.Size == info.Size() && qe.ModTime.Equal(info.ModTime())
+}
+
+// Quarantine is a persisted list of clips that libvlc failed to play, which
+// the selector passes over once they have failed often enough. Like the play history, it is shared between all
+// windows, and every change is written straight back to disk.
+type Quarantine struct {
+	sync.Mutex
+
+	path    string
+	entries map[string]*QuarantineEntry
+}
+
+// LoadQuarantine reads the quarantine list stored at path, dropping expired
+// entries. A missing or corrupt file just means starting with an empty list.
+func LoadQuarantine(path string) *Quarantine {
+	q := &Quarantine{
+		path:    path,
+		entries: map[string]*QuarantineEntry{},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Error reading quarantine list %v, starting afresh: %v", path, err)
+		}
+		return q
+	}
+
+	var entries map[string]*QuarantineEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Printf("Quarantine list %v is corrupt, rebuilding it: %v", path, err)
+
+		if err := os.Rename(path, path+".corrupt"); err != nil {
+			log.Printf("Error moving corrupt quarantine list aside: %v", err)
+		}
+		return q
+	}
+
+	for item, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		if time.Since(entry.LastFailed) > quarantineExpiry {
+			log.Printf("Releasing %v from quarantine", item)
+			continue
+		}
+		q.entries[item] = entry
+	}
+
+	return q
+}
+
+// save writes the list out. Must be called with the lock held.
+func (q *Quarantine) save() {
+	data, err := json.MarshalIndent(q.entries, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding quarantine list: %v", err)
+		return
+	}
+
+	tmpPath := q.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Printf("Error writing quarantine list %v: %v", tmpPath, err)
+		return
+	}
+
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		log.Printf("Error replacing quarantine list %v: %v", q.path, err)
+	}
+}
+
+// Add records that item failed to play, and why. Only failures to play
+// clips libvlc could open, reported as ErrPlayback, are counted; anything
+// else may well be temporary.
+func (q *Quarantine) Add(item string, reason error) {
+	if q == nil || !errors.Is(reason, ErrPlayback) {
+		return
+	}
+
+	var info fs.FileInfo
+	if !isMediaURL(item) {
+		var err error
+		if info, err = os.Stat(item); err != nil {
+			return
+		}
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	entry, ok := q.entries[item]
+	if !ok || (info != nil && !entry.sameFile(info)) {
+		entry = &QuarantineEntry{}
+		q.entries[item] = entry
+	}
+	if info != nil {
+		entry.Size = info.Size()
+		entry.ModTime = info.ModTime()
+	}
+	entry.Reason = reason.Error()
+	entry.Failures++
+	entry.LastFailed = time.Now()
+
+	if entry.excludes(item, entry.LastFailed) {
+		log.Printf("Quarantined %v after %d failures: %v", item, entry.Failures, entry.Reason)
+	} else {
+		log.Printf("Recorded failure %d of %v: %v", entry.Failures, item, entry.Reason)
+	}
+
+	q.save()
+}
+
+// Filter returns those items that aren't quarantined. Local clips whose
+// files have been replaced since they failed are released.
+func (q *Quarantine) Filter(items []MediaItem) []MediaItem {
+	if q == nil {
+		return items
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	now := time.Now()
+	var released bool
+
+	var ret []MediaItem
+	for _, item := range items {
+		entry, ok := q.entries[item.Location]
+		if ok && entry.excludes(item.Location, now) && q.replaced(item.Location, entry, now) {
+			log.Printf("Releasing %v from quarantine, as it has changed", item.Location)
+			delete(q.entries, item.Location)
+			released = true
+			ok = false
+		}
+		if !ok || !entry.excludes(item.Location, now) {
+			ret = append(ret, item)
+		}
+	}
+
+	if released {
+		q.save()
+	}
+	return ret
+}
+
+// replaced reports whether the local file item has changed since entry was
+// recorded. Files are only looked at now and then, as there may be a few in
+// every pick. Must be called with the lock held.
+func (q *Quarantine) replaced(item string, entry *QuarantineEntry, now time.Time) bool {
+	if isMediaURL(item) || now.Sub(entry.checked) < quarantineRecheckInterval {
+		return false
+	}
+	entry.checked = now
+
+	info, err := os.Stat(item)
+	return err == nil && !entry.sameFile(info)
+}
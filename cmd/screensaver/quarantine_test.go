@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// playbackError is a failure the quarantine counts.
+func playbackError(message string) error {
+	return fmt.Errorf("%w: %s", ErrPlayback, message)
+}
+
+func TestQuarantinePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quarantine.json")
+	dir := t.TempDir()
+	broken, fine := filepath.Join(dir, "broken.mp4"), filepath.Join(dir, "fine.mp4")
+	writeTestFile(t, broken, "broken")
+	writeTestFile(t, fine, "fine")
+
+	q := LoadQuarantine(path)
+	for i := 1; i < quarantineThreshold; i++ {
+		q.Add(broken, playbackError("no suitable decoder"))
+	}
+	if items := q.Filter([]MediaItem{{Location: broken}}); len(items) != 1 {
+		t.Errorf("Expected a clip to be given another chance before the threshold, got %v", items)
+	}
+	q.Add(broken, playbackError("still no suitable decoder"))
+
+	q = LoadQuarantine(path)
+	entry, ok := q.entries[broken]
+	if !ok {
+		t.Fatalf("Quarantine entry was not persisted")
+	}
+	if entry.Failures != quarantineThreshold || entry.Reason != ErrPlayback.Error()+": still no suitable decoder" || entry.Size != int64(len("broken")) {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	items := q.Filter([]MediaItem{{Location: broken}, {Location: fine}})
+	if len(items) != 1 || items[0].Location != fine {
+		t.Errorf("Expected only the working clip, got %v", items)
+	}
+}
+
+func TestQuarantineIgnoresOtherFailures(t *testing.T) {
+	q := LoadQuarantine(filepath.Join(t.TempDir(), "quarantine.json"))
+	clip := filepath.Join(t.TempDir(), "clip.mp4")
+	writeTestFile(t, clip, "clip")
+
+	q.Add(clip, errors.New("unable to open"))
+	q.Add(filepath.Join(t.TempDir(), "offline.mp4"), playbackError("share offline"))
+	if len(q.entries) != 0 {
+		t.Errorf("Expected only playback failures of files that are there to count, got %v", q.entries)
+	}
+}
+
+func TestQuarantineReleasesReplacedFiles(t *testing.T) {
+	q := LoadQuarantine(filepath.Join(t.TempDir(), "quarantine.json"))
+	clip := filepath.Join(t.TempDir(), "clip.mp4")
+	writeTestFile(t, clip, "broken")
+
+	for i := 0; i < quarantineThreshold; i++ {
+		q.Add(clip, playbackError("corrupt"))
+	}
+	if items := q.Filter([]MediaItem{{Location: clip}}); len(items) != 0 {
+		t.Fatalf("Expected the clip to be quarantined, got %v", items)
+	}
+
+	writeTestFile(t, clip, "fixed at last")
+	q.entries[clip].checked = time.Time{}
+	if items := q.Filter([]MediaItem{{Location: clip}}); len(items) != 1 {
+		t.Errorf("Expected the replaced clip to be released, got %v", items)
+	}
+	if _, ok := q.entries[clip]; ok {
+		t.Errorf("Expected the replaced clip's entry to be dropped")
+	}
+}
+
+func TestQuarantineURLBackoff(t *testing.T) {
+	q := LoadQuarantine(filepath.Join(t.TempDir(), "quarantine.json"))
+	const stream = "http://stream/live"
+
+	q.Add(stream, playbackError("connection refused"))
+	if items := q.Filter([]MediaItem{{Location: stream}}); len(items) != 0 {
+		t.Fatalf("Expected the stream to be left out for a while, got %v", items)
+	}
+
+	q.entries[stream].LastFailed = time.Now().Add(-urlRetryDelay)
+	if items := q.Filter([]MediaItem{{Location: stream}}); len(items) != 1 {
+		t.Errorf("Expected the stream to be retried, got %v", items)
+	}
+
+	for _, test := range []struct {
+		failures int
+		ago      time.Duration
+		excluded bool
+	}{
+		{2, urlRetryDelay, true},
+		{2, 2 * urlRetryDelay, false},
+		{20, maxURLRetryDelay - time.Minute, true},
+		{20, maxURLRetryDelay, false},
+	} {
+		entry := QuarantineEntry{Failures: test.failures, LastFailed: time.Now().Add(-test.ago)}
+		if got := entry.excludes(stream, time.Now()); got != test.excluded {
+			t.Errorf("%d failures %v ago: excluded %v, expected %v", test.failures, test.ago, got, test.excluded)
+		}
+	}
+}
+
+func TestQuarantineExpiry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quarantine.json")
+
+	data, _ := json.Marshal(map[string]QuarantineEntry{
+		"old.mp4": {Reason: "share offline", Failures: 1, LastFailed: time.Now().Add(-quarantineExpiry - time.Hour)},
+		"new.mp4": {Reason: "corrupt", Failures: 1, LastFailed: time.Now()},
+	})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	q := LoadQuarantine(path)
+	if _, ok := q.entries["old.mp4"]; ok {
+		t.Errorf("Expected the old entry to have expired")
+	}
+	if _, ok := q.entries["new.mp4"]; !ok {
+		t.Errorf("Expected the new entry to still be quarantined")
+	}
+}
+
+func TestMediaSelectorSkipsQuarantined(t *testing.T) {
+	q := LoadQuarantine(filepath.Join(t.TempDir(), "quarantine.json"))
+	q.Add("http://broken/1", playbackError("404"))
+	q.Add("http://broken/2", playbackError("404"))
+
+	sources := []MediaSource{
+		{Type: URLListSource, URLs: []string{"http://broken/1", "http://broken/2"}, Weight: 100, Enabled: true},
+		{Type: URLListSource, URLs: []string{"http://fine/1", "http://broken/1"}, Weight: 1, Enabled: true},
+	}
+
+	ms := NewMediaSelector(sources, DefaultMediaExtensions, ShuffleSelection, nil, 1)
+	ms.Quarantine = q
+
+	for i := 0; i < 20; i++ {
+		if item, err := ms.Next(); err != nil || item.Location != "http://fine/1" {
+			t.Fatalf("Expected the only unquarantined clip, got %v %v", item, err)
+		}
+	}
+
+	q.Add("http://fine/1", playbackError("decoder error"))
+	if _, err := ms.Next(); err != ErrNoMediaFound {
+		t.Errorf("Expected ErrNoMediaFound with everything quarantined, got %v", err)
+	}
+}
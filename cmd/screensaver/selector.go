@@ -0,0 +1,278 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+)
+
+// SelectionMode controls how the next item is chosen from a source.
+type SelectionMode string
+
+const (
+	// ShuffleSelection deals from a shuffled deck, see Playlist.
+	ShuffleSelection SelectionMode = "shuffle"
+	// LeastRecentSelection favours items that haven't been played recently,
+	// or have been played the fewest times, according to the play history.
+	LeastRecentSelection SelectionMode = "least-recent"
+)
+
+func parseSelectionMode(value string) SelectionMode {
+	switch SelectionMode(value) {
+	case LeastRecentSelection:
+		return LeastRecentSelection
+	case ShuffleSelection, "":
+		return ShuffleSelection
+	}
+
+	log.Printf("Unknown selection mode %q, using %q", value, ShuffleSelection)
+	return ShuffleSelection
+}
+
+// sourceState is what a selector remembers about each of its sources between
+// picks.
+type sourceState struct {
+	playlist *Playlist
+	// position is where a source played in order is up to.
+	position int
+}
+
+// MediaSelector chooses what to play next: first a source, at random in
+// proportion to the source weights, then an item from it according to Mode,
+// or the next in order for sources played in order.
+//
+// If TargetAspect is set, clips may also be matched to it according to
+// AspectMatching, with Geometry used to find their shapes. If Index is set,
+// directories are listed from it; if Quarantine is set, the items in it are
+// passed over.
+type MediaSelector struct {
+	Sources    []MediaSource
+	Extensions []string
+	Mode       SelectionMode
+	History    *PlayHistory
+	Index      *MediaIndex
+	Quarantine *Quarantine
+
+	AspectMatching AspectMatching
+	TargetAspect   float64
+	Geometry       GeometryFunc
+
+	rng    *rand.Rand
+	states []sourceState
+}
+
+func NewMediaSelector(sources []MediaSource, extensions []string, mode SelectionMode, history *PlayHistory, seed int64) *MediaSelector {
+	ms := &MediaSelector{
+		Sources:    sources,
+		Extensions: extensions,
+		Mode:       mode,
+		History:    history,
+		rng:        rand.New(rand.NewSource(seed)),
+		states:     make([]sourceState, len(sources)),
+	}
+
+	for i := range ms.states {
+		ms.states[i].playlist = NewPlaylist(ms.rng.Int63(), DefaultPlaylistNoRepeat)
+	}
+
+	return ms
+}
+
+// pickSource chooses one of the candidate source indices by weight.
+func (ms *MediaSelector) pickSource(candidates []int) int {
+	var total float64
+	for _, index := range candidates {
+		total += ms.Sources[index].Weight
+	}
+
+	point := ms.rng.Float64() * total
+	for _, index := range candidates {
+		point -= ms.Sources[index].Weight
+		if point < 0 {
+			return index
+		}
+	}
+
+	// Only reachable through floating point rounding.
+	return candidates[len(candidates)-1]
+}
+
+func removeCandidate(candidates []int, index int) []int {
+	for i, candidate := range candidates {
+		if candidate == index {
+			return append(candidates[:i], candidates[i+1:]...)
+		}
+	}
+	return candidates
+}
+
+func (ms *MediaSelector) matchingAspect() bool {
+	return ms.AspectMatching.Mode != AspectMatchOff && ms.AspectMatching.Mode != "" &&
+		ms.TargetAspect > 0 && ms.Geometry != nil
+}
+
+// Next returns the next item to play. Sources that fail to load or turn out
+// to be empty are logged and passed over for this pick.
+func (ms *MediaSelector) Next() (MediaItem, error) {
+	var candidates []int
+	for index, source := range ms.Sources {
+		if source.Enabled && source.Weight > 0 {
+			candidates = append(candidates, index)
+		}
+	}
+
+	// Should no source have clips matching the aspect ratio, we fall back to
+	// the first source that loaded, or the closest clip from any source.
+	var fallbackSource = -1
+	var fallbackItems []MediaItem
+	var loadedItems []MediaItem
+
+	for len(candidates) > 0 {
+		index := ms.pickSource(candidates)
+
+		items, err := ms.Sources[index].Load(ms.Extensions, ms.Index)
+		if err == nil {
+			if items = ms.Quarantine.Filter(items); len(items) == 0 {
+				err = ErrAllQuarantined
+			}
+		}
+		if err != nil {
+			log.Printf("Skipping media source %v: %v", ms.Sources[index], err)
+			candidates = removeCandidate(candidates, index)
+			continue
+		}
+
+		if playback := ms.Sources[index].Playback(); playback.limited() {
+			items = withPlayback(items, playback)
+		}
+
+		if !ms.matchingAspect() {
+			return ms.pickItem(index, items), nil
+		}
+
+		if matching := ms.AspectMatching.filter(items, ms.TargetAspect, ms.Geometry); len(matching) > 0 {
+			return ms.pickItem(index, matching), nil
+		}
+
+		if ms.AspectMatching.Mode == AspectMatchPrefer {
+			return ms.pickFallback(index, items), nil
+		}
+
+		if fallbackItems == nil {
+			fallbackSource, fallbackItems = index, items
+		}
+		loadedItems = append(loadedItems, items...)
+		candidates = removeCandidate(candidates, index)
+	}
+
+	if fallbackItems != nil {
+		log.Printf("No clips match aspect ratio %.2f, falling back to %v", ms.TargetAspect, ms.AspectMatching.Fallback)
+
+		if ms.AspectMatching.Fallback == AspectFallbackClosest {
+			if item, ok := closestAspect(loadedItems, ms.TargetAspect, ms.Geometry); ok {
+				return item, nil
+			}
+		}
+		return ms.pickItem(fallbackSource, fallbackItems), nil
+	}
+
+	return MediaItem{}, ErrNoMediaFound
+}
+
+// withPlayback returns a copy of items with the source's playback options.
+// The rest of each item's options come from its sidecars, and are kept.
+func withPlayback(items []MediaItem, playback PlaybackOptions) []MediaItem {
+	ret := make([]MediaItem, len(items))
+	for i, item := range items {
+		item.Playback.RandomStart = playback.RandomStart
+		item.Playback.MaxDuration = playback.MaxDuration
+		ret[i] = item
+	}
+	return ret
+}
+
+// itemWeight is how likely an item is to be picked in shuffle mode, relative
+// to the others in its source.
+func itemWeight(item MediaItem) float64 {
+	if item.Weight > 0 {
+		return item.Weight
+	}
+	return 1
+}
+
+// weighted reports whether any sidecar has changed an item's weight.
+func weighted(items []MediaItem) bool {
+	for _, item := range items {
+		if itemWeight(item) != 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// pickWeighted chooses an item at random in proportion to its weight.
+func (ms *MediaSelector) pickWeighted(items []MediaItem) MediaItem {
+	var total float64
+	for _, item := range items {
+		total += itemWeight(item)
+	}
+
+	point := ms.rng.Float64() * total
+	for _, item := range items {
+		point -= itemWeight(item)
+		if point < 0 {
+			return item
+		}
+	}
+
+	// Only reachable through floating point rounding.
+	return items[len(items)-1]
+}
+
+// pickFallback chooses from a source with no clips of the right shape.
+func (ms *MediaSelector) pickFallback(source int, items []MediaItem) MediaItem {
+	if ms.AspectMatching.Fallback == AspectFallbackClosest {
+		if item, ok := closestAspect(items, ms.TargetAspect, ms.Geometry); ok {
+			return item
+		}
+	}
+
+	return ms.pickItem(source, items)
+}
+
+// pickItem chooses from a source's items, which must not be empty.
+func (ms *MediaSelector) pickItem(source int, items []MediaItem) MediaItem {
+	state := &ms.states[source]
+
+	if ms.Sources[source].InOrder {
+		i := state.position % len(items)
+		state.position = i + 1
+		return items[i]
+	}
+
+	// Weights can't be honoured by dealing from a shuffled deck, so weighted
+	// items are drawn at random instead.
+	if (ms.Mode == ShuffleSelection || ms.Mode == "") && weighted(items) {
+		return ms.pickWeighted(items)
+	}
+
+	locations := make([]string, len(items))
+	for i, item := range items {
+		locations[i] = item.Location
+	}
+
+	var location string
+	if ms.Mode == LeastRecentSelection {
+		location, _ = ms.History.LeastRecentlyPlayed(locations, ms.rng)
+	} else {
+		state.playlist.SetItems(locations)
+		location, _ = state.playlist.Next()
+	}
+
+	for _, item := range items {
+		if item.Location == location {
+			return item
+		}
+	}
+
+	return MediaItem{Location: location}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMediaSelectorWeights(t *testing.T) {
+	sources := []MediaSource{
+		{Type: URLListSource, URLs: []string{"http://drone/1", "http://drone/2"}, Weight: 7, Enabled: true},
+		{Type: URLListSource, URLs: []string{"http://office/1", "http://office/2"}, Weight: 3, Enabled: true},
+		{Type: URLListSource, URLs: []string{"http://disabled/1"}, Weight: 100, Enabled: false},
+		{Type: URLListSource, URLs: []string{"http://zero/1"}, Weight: 0, Enabled: true},
+		{Type: DirectorySource, Path: filepath.Join(t.TempDir(), "missing"), Weight: 100, Enabled: true},
+	}
+
+	ms := NewMediaSelector(sources, DefaultMediaExtensions, ShuffleSelection, nil, 1)
+
+	counts := map[string]int{}
+	const picks = 10000
+	for i := 0; i < picks; i++ {
+		item, err := ms.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		host := strings.Split(item.Location, "/")[2]
+		counts[host]++
+	}
+
+	if counts["disabled"] != 0 || counts["zero"] != 0 {
+		t.Errorf("Picked from a source that should be skipped: %v", counts)
+	}
+
+	drone := float64(counts["drone"]) / picks
+	if drone < 0.67 || drone > 0.73 {
+		t.Errorf("Expected about 70%% drone footage, got %v", counts)
+	}
+}
+
+func TestMediaSelectorInOrder(t *testing.T) {
+	sources := []MediaSource{
+		{Type: URLListSource, URLs: []string{"http://a", "http://b", "http://a", "http://c"}, Weight: 1, Enabled: true, InOrder: true},
+	}
+
+	ms := NewMediaSelector(sources, DefaultMediaExtensions, ShuffleSelection, nil, 1)
+
+	for _, expected := range []string{"http://a", "http://b", "http://a", "http://c", "http://a"} {
+		if item, _ := ms.Next(); item.Location != expected {
+			t.Errorf("Expected %s, got %s", expected, item.Location)
+		}
+	}
+}
+
+func TestMediaSelectorNoSources(t *testing.T) {
+	ms := NewMediaSelector(nil, DefaultMediaExtensions, ShuffleSelection, nil, 1)
+	if _, err := ms.Next(); err != ErrNoMediaFound {
+		t.Errorf("Expected ErrNoMediaFound, got %v", err)
+	}
+}
+
+func TestMediaSelectorAspectMatching(t *testing.T) {
+	geometries := map[string]VideoGeometry{
+		"http://landscape/1": {Width: 1920, Height: 1080},
+		"http://landscape/2": {Width: 1280, Height: 720},
+		"http://portrait/1":  {Width: 1080, Height: 1920},
+		"http://square/1":    {Width: 1000, Height: 1000},
+	}
+	geometry := func(location string) (VideoGeometry, bool) {
+		g, ok := geometries[location]
+		return g, ok
+	}
+
+	landscape := MediaSource{Type: URLListSource, URLs: []string{"http://landscape/1", "http://landscape/2", "http://unknown/1"}, Weight: 1, Enabled: true}
+	mixed := MediaSource{Type: URLListSource, URLs: []string{"http://portrait/1", "http://landscape/1", "http://square/1"}, Weight: 1, Enabled: true}
+
+	newSelector := func(mode AspectMatchMode, fallback AspectFallback, sources ...MediaSource) *MediaSelector {
+		ms := NewMediaSelector(sources, DefaultMediaExtensions, ShuffleSelection, nil, 1)
+		ms.AspectMatching = AspectMatching{Mode: mode, Tolerance: DefaultAspectTolerance, Fallback: fallback}
+		ms.TargetAspect = 1080.0 / 1920.0
+		ms.Geometry = geometry
+		return ms
+	}
+
+	// Require: the landscape-only source is skipped entirely
+	ms := newSelector(AspectMatchRequire, AspectFallbackAny, landscape, mixed)
+	for i := 0; i < 20; i++ {
+		if item, _ := ms.Next(); item.Location != "http://portrait/1" {
+			t.Fatalf("require: expected the portrait clip, got %s", item.Location)
+		}
+	}
+
+	// Nothing matches, so fall back to the closest shape
+	ms = newSelector(AspectMatchRequire, AspectFallbackClosest, landscape, MediaSource{
+		Type: URLListSource, URLs: []string{"http://square/1"}, Weight: 1, Enabled: true,
+	})
+	for i := 0; i < 20; i++ {
+		if item, _ := ms.Next(); item.Location != "http://square/1" {
+			t.Fatalf("closest: expected the square clip, got %s", item.Location)
+		}
+	}
+
+	// Prefer: falls back within the chosen source
+	ms = newSelector(AspectMatchPrefer, AspectFallbackAny, landscape)
+	seen := map[string]bool{}
+	for i := 0; i < 30; i++ {
+		item, _ := ms.Next()
+		seen[item.Location] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("prefer: expected every clip to be played when none match, got %v", seen)
+	}
+}
+
+func TestAspectMatchingAllowsForRotation(t *testing.T) {
+	geometry := func(location string) (VideoGeometry, bool) {
+		return VideoGeometry{Width: 1920, Height: 1080}, true
+	}
+	items := []MediaItem{
+		{Location: "upright.mp4"},
+		{Location: "turned.mp4", Playback: PlaybackOptions{Rotation: 90}},
+		{Location: "upside-down.mp4", Playback: PlaybackOptions{Rotation: 180}},
+		{Location: "turned-back.mp4", Playback: PlaybackOptions{Rotation: 270}},
+	}
+	am := AspectMatching{Mode: AspectMatchRequire, Tolerance: DefaultAspectTolerance}
+
+	portrait := am.filter(items, 1080.0/1920.0, geometry)
+	if len(portrait) != 2 || portrait[0].Location != "turned.mp4" || portrait[1].Location != "turned-back.mp4" {
+		t.Errorf("Expected the rotated clips to match a portrait monitor, got %v", portrait)
+	}
+	if item, ok := closestAspect(items[:2], 1080.0/1920.0, geometry); !ok || item.Location != "turned.mp4" {
+		t.Errorf("Expected the rotated clip to be closest to portrait, got %v", item)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// folderSidecarName is the sidecar that applies to every clip in a folder.
+// A clip's own sidecar is its file name with ".json" appended, e.g.
+// clip.mp4.json, and takes precedence.
+const folderSidecarName = "screensaver.json"
+
+// Timestamp is a time into a clip. Sidecars can give it as a number of
+// seconds or as "[[h:]m:]s[.fff]".
+type Timestamp time.Duration
+
+func (ts *Timestamp) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*ts = Timestamp(seconds * float64(time.Second))
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("timestamp must be a number of seconds or a string, not %s", data)
+	}
+
+	var total float64
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return fmt.Errorf("invalid timestamp %q", value)
+	}
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid timestamp %q", value)
+		}
+		total = total*60 + n
+	}
+
+	*ts = Timestamp(total * float64(time.Second))
+	return nil
+}
+
+// Sidecar is the per-clip metadata read from sidecar files, which trims or
+// otherwise adjusts a clip without re-encoding it.
+type Sidecar struct {
+	In       Timestamp `json:"in"`
+	Out      Timestamp `json:"out"`
+	Rate     float64   `json:"rate"`
+	Rotation int       `json:"rotation"`
+	Weight   *float64  `json:"weight"`
+	Tags     []string  `json:"tags"`
+	Exclude  bool      `json:"exclude"`
+}
+
+func (sc Sidecar) validate() error {
+	if sc.In < 0 || sc.Out < 0 {
+		return fmt.Errorf("negative in or out point")
+	}
+	if sc.Out > 0 && sc.Out <= sc.In {
+		return fmt.Errorf("out point %v is not after in point %v", time.Duration(sc.Out), time.Duration(sc.In))
+	}
+	if sc.Rate < 0 {
+		return fmt.Errorf("negative rate %g", sc.Rate)
+	}
+	switch sc.Rotation {
+	case 0, 90, 180, 270:
+	default:
+		return fmt.Errorf("rotation %d is not a multiple of 90", sc.Rotation)
+	}
+	if sc.Weight != nil && *sc.Weight < 0 {
+		return fmt.Errorf("negative weight %g", *sc.Weight)
+	}
+	return nil
+}
+
+// apply returns item as adjusted by the sidecar, or false if the sidecar
+// leaves it out.
+func (sc Sidecar) apply(item MediaItem) (MediaItem, bool) {
+	if sc.Exclude || (sc.Weight != nil && *sc.Weight == 0) {
+		return item, false
+	}
+
+	item.Playback.In = time.Duration(sc.In)
+	item.Playback.Out = time.Duration(sc.Out)
+	item.Playback.Rate = sc.Rate
+	item.Playback.Rotation = sc.Rotation
+	item.Tags = sc.Tags
+	if sc.Weight != nil {
+		item.Weight = *sc.Weight
+	}
+
+	return item, true
+}
+
+// sidecarLayer is one sidecar file's worth of settings, not yet decoded, so
+// that layers can be decoded on top of each other: a later layer only
+// overrides the settings it gives.
+type sidecarLayer struct {
+	name string
+	data []byte
+}
+
+// validSidecarLayer checks a layer decodes and makes sense on its own. If it
+// doesn't, it is logged and left out.
+func validSidecarLayer(name string, data []byte) bool {
+	var sc Sidecar
+	err := json.Unmarshal(data, &sc)
+	if err == nil {
+		err = sc.validate()
+	}
+	if err != nil {
+		log.Printf("Ignoring invalid sidecar %v: %v", name, err)
+		return false
+	}
+	return true
+}
+
+// sidecarFolder is what we know of the sidecars in one folder.
+type sidecarFolder struct {
+	// folder holds the folder-wide settings, and files the per-clip ones
+	// from the folder sidecar's "files" object.
+	folder *sidecarLayer
+	files  map[string]*sidecarLayer
+	// clips holds each clip's own sidecar, by the clip's file name.
+	clips map[string]*sidecarLayer
+}
+
+func loadSidecarFolder(dir string) *sidecarFolder {
+	sf := &sidecarFolder{
+		files: map[string]*sidecarLayer{},
+		clips: map[string]*sidecarLayer{},
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return sf
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isSidecarFile(name) {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Error reading sidecar %v: %v", path, err)
+			continue
+		}
+
+		if strings.EqualFold(name, folderSidecarName) {
+			sf.loadFolder(path, data)
+			continue
+		}
+
+		if validSidecarLayer(path, data) {
+			clip := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+			sf.clips[clip] = &sidecarLayer{name: path, data: data}
+		}
+	}
+
+	return sf
+}
+
+func (sf *sidecarFolder) loadFolder(path string, data []byte) {
+	if !validSidecarLayer(path, data) {
+		return
+	}
+	sf.folder = &sidecarLayer{name: path, data: data}
+
+	var folder struct {
+		Files map[string]json.RawMessage `json:"files"`
+	}
+	if err := json.Unmarshal(data, &folder); err != nil {
+		return
+	}
+
+	for clip, data := range folder.Files {
+		name := path + ": " + clip
+		if validSidecarLayer(name, data) {
+			sf.files[strings.ToLower(clip)] = &sidecarLayer{name: name, data: data}
+		}
+	}
+}
+
+// get returns the combined sidecar for the named clip, and whether any
+// sidecar applies to it at all.
+func (sf *sidecarFolder) get(clip string) (Sidecar, bool) {
+	var sc Sidecar
+	var found bool
+
+	clip = strings.ToLower(clip)
+	for _, layer := range []*sidecarLayer{sf.folder, sf.files[clip], sf.clips[clip]} {
+		if layer == nil {
+			continue
+		}
+		// Each layer decoded on its own already, so this can't fail.
+		json.NewDecoder(bytes.NewReader(layer.data)).Decode(&sc)
+		found = true
+	}
+
+	if found {
+		if err := sc.validate(); err != nil {
+			log.Printf("Ignoring sidecars for %v: %v", clip, err)
+			return Sidecar{}, false
+		}
+	}
+
+	return sc, found
+}
+
+// sidecarCache holds the sidecars of every folder we have played from. A
+// folder's sidecars are read when they are first wanted, unless the index
+// got to them first, and reread in the background: when the index rescans
+// the folder, the watcher sees a sidecar change, or a playlist listing
+// clips in the folder is rechecked.
+type sidecarCache struct {
+	sync.Mutex
+
+	folders map[string]*sidecarFolder
+}
+
+var mediaSidecars = &sidecarCache{folders: map[string]*sidecarFolder{}}
+
+func (sc *sidecarCache) folder(dir string) *sidecarFolder {
+	sc.Lock()
+	sf, ok := sc.folders[dir]
+	sc.Unlock()
+
+	if !ok {
+		sf = sc.Reload(dir)
+	}
+	return sf
+}
+
+// Reload rereads the sidecars in dir.
+func (sc *sidecarCache) Reload(dir string) *sidecarFolder {
+	sf := loadSidecarFolder(dir)
+
+	sc.Lock()
+	sc.folders[dir] = sf
+	sc.Unlock()
+
+	return sf
+}
+
+// isSidecarFile reports whether path could be a sidecar.
+func isSidecarFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// Apply adjusts local items according to their sidecars, dropping those the
+// sidecars exclude. URLs have no sidecars.
+func (sc *sidecarCache) Apply(items []MediaItem) []MediaItem {
+	var ret []MediaItem
+
+	for _, item := range items {
+		if isMediaURL(item.Location) {
+			ret = append(ret, item)
+			continue
+		}
+
+		sidecar, ok := sc.folder(filepath.Dir(item.Location)).get(filepath.Base(item.Location))
+		if !ok {
+			ret = append(ret, item)
+			continue
+		}
+
+		if item, ok = sidecar.apply(item); ok {
+			ret = append(ret, item)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimestampUnmarshal(t *testing.T) {
+	cases := map[string]time.Duration{
+		`12.5`:         12500 * time.Millisecond,
+		`"42"`:         42 * time.Second,
+		`"1:30"`:       90 * time.Second,
+		`"1:02:03.25"`: time.Hour + 2*time.Minute + 3250*time.Millisecond,
+	}
+
+	for input, expected := range cases {
+		var ts Timestamp
+		if err := json.Unmarshal([]byte(input), &ts); err != nil {
+			t.Errorf("Unable to parse %v: %v", input, err)
+		} else if time.Duration(ts) != expected {
+			t.Errorf("Expected %v for %v, got %v", expected, input, time.Duration(ts))
+		}
+	}
+
+	for _, input := range []string{`"1:2:3:4"`, `"ten"`, `"-5"`, `true`} {
+		var ts Timestamp
+		if err := json.Unmarshal([]byte(input), &ts); err == nil {
+			t.Errorf("Expected an error for %v", input)
+		}
+	}
+}
+
+func writeSidecarFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSidecarLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeSidecarFiles(t, dir, map[string]string{
+		"a.mp4": "", "b.mp4": "", "c.mp4": "", "d.mp4": "", "e.mp4": "",
+		folderSidecarName: `{
+			"rate": 0.5,
+			"tags": ["nature"],
+			"files": {
+				"b.mp4": {"in": "0:10", "out": 60},
+				"c.mp4": {"exclude": true},
+				"d.mp4": {"rotation": 45}
+			}
+		}`,
+		"B.MP4.json": `{"rate": 2, "weight": 3}`,
+		"e.mp4.json": `{not json`,
+	})
+
+	var items []MediaItem
+	for _, name := range []string{"a.mp4", "b.mp4", "c.mp4", "d.mp4", "e.mp4"} {
+		items = append(items, MediaItem{Location: filepath.Join(dir, name)})
+	}
+	items = append(items, MediaItem{Location: "http://elsewhere/c.mp4"})
+
+	byName := map[string]MediaItem{}
+	for _, item := range mediaSidecars.Apply(items) {
+		byName[filepath.Base(item.Location)] = item
+	}
+
+	if len(byName) != 5 {
+		t.Fatalf("Expected c.mp4 to be excluded, got %v", byName)
+	}
+	if a := byName["a.mp4"]; a.Playback.Rate != 0.5 || !a.HasTag("Nature") {
+		t.Errorf("Expected folder defaults for a.mp4, got %+v", a)
+	}
+
+	b := byName["b.mp4"]
+	if b.Playback.In != 10*time.Second || b.Playback.Out != time.Minute {
+		t.Errorf("Expected b.mp4 trimmed to 0:10-1:00, got %v-%v", b.Playback.In, b.Playback.Out)
+	}
+	if b.Playback.Rate != 2 || b.Weight != 3 || !b.HasTag("nature") {
+		t.Errorf("Expected b.mp4's own sidecar on top of the folder's, got %+v", b)
+	}
+
+	// Invalid sidecars are ignored, leaving the folder's settings
+	if d := byName["d.mp4"]; d.Playback.Rotation != 0 || d.Playback.Rate != 0.5 {
+		t.Errorf("Expected d.mp4's invalid rotation to be ignored, got %+v", d)
+	}
+	if e := byName["e.mp4"]; e.Playback.Rate != 0.5 {
+		t.Errorf("Expected e.mp4's corrupt sidecar to be ignored, got %+v", e)
+	}
+}
+
+func TestSidecarReload(t *testing.T) {
+	dir := t.TempDir()
+	writeSidecarFiles(t, dir, map[string]string{
+		"a.mp4":      "",
+		"a.mp4.json": `{"rate": 2}`,
+	})
+	items := []MediaItem{{Location: filepath.Join(dir, "a.mp4")}}
+
+	if applied := mediaSidecars.Apply(items); applied[0].Playback.Rate != 2 {
+		t.Fatalf("Expected a.mp4's sidecar to be read, got %+v", applied[0])
+	}
+
+	// Choosing what to play doesn't look at the disk again...
+	writeSidecarFiles(t, dir, map[string]string{"a.mp4.json": `{"rate": 3}`})
+	if applied := mediaSidecars.Apply(items); applied[0].Playback.Rate != 2 {
+		t.Errorf("Expected the cached sidecar, got %+v", applied[0])
+	}
+
+	// ...but scanning the folder does.
+	mi := LoadMediaIndex(filepath.Join(t.TempDir(), "index.json"))
+	if err := mi.Rescan(dir, DefaultMediaExtensions, true); err != nil {
+		t.Fatal(err)
+	}
+	if applied := mediaSidecars.Apply(items); applied[0].Playback.Rate != 3 {
+		t.Errorf("Expected the sidecar to be reread by a rescan, got %+v", applied[0])
+	}
+}
+
+func TestMediaSourceTags(t *testing.T) {
+	dir := t.TempDir()
+	writeSidecarFiles(t, dir, map[string]string{
+		"beach.mp4": "", "city.mp4": "", "forest.mp4": "",
+		"beach.mp4.json":  `{"tags": ["sea", "calm"]}`,
+		"forest.mp4.json": `{"tags": ["Calm"]}`,
+	})
+
+	source := MediaSource{Type: DirectorySource, Path: dir, Weight: 1, Enabled: true, Tags: []string{"calm"}}
+	items, err := source.Load(DefaultMediaExtensions, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 2 {
+		t.Errorf("Expected only the calm clips, got %v", items)
+	}
+	for _, item := range items {
+		if filepath.Base(item.Location) == "city.mp4" {
+			t.Errorf("Untagged clip %v was included", item)
+		}
+	}
+}
+
+func TestMediaSelectorItemWeights(t *testing.T) {
+	dir := t.TempDir()
+	writeSidecarFiles(t, dir, map[string]string{
+		"often.mp4": "", "rarely.mp4": "", "never.mp4": "",
+		"often.mp4.json": `{"weight": 9, "in": 5}`,
+		"never.mp4.json": `{"weight": 0}`,
+	})
+
+	sources := []MediaSource{
+		{Type: DirectorySource, Path: dir, Weight: 1, Enabled: true, MaxMinutes: 1},
+	}
+	ms := NewMediaSelector(sources, DefaultMediaExtensions, ShuffleSelection, nil, 1)
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		item, err := ms.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[filepath.Base(item.Location)]++
+
+		if filepath.Base(item.Location) == "often.mp4" &&
+			(item.Playback.In != 5*time.Second || item.Playback.MaxDuration != time.Minute) {
+			t.Fatalf("Expected sidecar and source playback options to combine, got %+v", item.Playback)
+		}
+	}
+
+	if counts["never.mp4"] != 0 {
+		t.Errorf("A clip with zero weight was picked %d times", counts["never.mp4"])
+	}
+	if counts["often.mp4"] < 800 || counts["rarely.mp4"] < 50 {
+		t.Errorf("Expected picks in proportion to weight, got %v", counts)
+	}
+}
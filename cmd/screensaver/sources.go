@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MediaSourceType says where a MediaSource gets its items from.
+type MediaSourceType string
+
+const (
+	DirectorySource MediaSourceType = "directory"
+	PlaylistSource  MediaSourceType = "playlist"
+	URLListSource   MediaSourceType = "urls"
+)
+
+// MediaSource is one place videos come from. When there are several, the
+// selector picks a source at random in proportion to its weight, then an
+// item from within that source.
+//
+// Long recordings can be started at a random point, and cut short after
+// MaxMinutes, so that a screensaver session doesn't dwell on one of them.
+// Giving Tags restricts the source to clips whose sidecars carry at least one
+// of them. InOrder plays the source's items in the order they are listed,
+// whatever the selection mode, which is mostly useful for playlists.
+type MediaSource struct {
+	Type        MediaSourceType `json:"type"`
+	Path        string          `json:"path,omitempty"`
+	URLs        []string        `json:"urls,omitempty"`
+	Weight      float64         `json:"weight"`
+	Enabled     bool            `json:"enabled"`
+	Recursive   bool            `json:"recursive"`
+	RandomStart bool            `json:"randomStart,omitempty"`
+	MaxMinutes  float64         `json:"maxMinutes,omitempty"`
+	Tags        []string        `json:"tags,omitempty"`
+	InOrder     bool            `json:"inOrder,omitempty"`
+}
+
+// DefaultSourceWeight is given to sources which don't specify a weight.
+const DefaultSourceWeight = 1
+
+// UnmarshalJSON decodes a source with the same defaults as newMediaSource:
+// enabled, including subfolders, and with the default weight, unless it says
+// otherwise.
+func (ms *MediaSource) UnmarshalJSON(data []byte) error {
+	type plainSource MediaSource
+	source := plainSource{Weight: DefaultSourceWeight, Enabled: true, Recursive: true}
+	if err := json.Unmarshal(data, &source); err != nil {
+		return err
+	}
+
+	*ms = MediaSource(source)
+	return nil
+}
+
+// newMediaSource creates an enabled source for path, working out from the
+// extension whether it is a playlist or a directory.
+func newMediaSource(path string) MediaSource {
+	var sourceType = DirectorySource
+	if isPlaylistFile(path) {
+		sourceType = PlaylistSource
+	}
+
+	return MediaSource{
+		Type:      sourceType,
+		Path:      path,
+		Weight:    DefaultSourceWeight,
+		Enabled:   true,
+		Recursive: true,
+	}
+}
+
+// String describes the source for the configuration window and the log.
+func (ms MediaSource) String() string {
+	var desc string
+
+	switch ms.Type {
+	case URLListSource:
+		desc = fmt.Sprintf("%d URLs", len(ms.URLs))
+	case DirectorySource:
+		desc = ms.Path
+		if ms.Recursive {
+			desc += " (recursive)"
+		}
+	default:
+		desc = ms.Path
+	}
+
+	if ms.InOrder {
+		desc += " [in order]"
+	}
+	if ms.RandomStart {
+		desc += " [random start]"
+	}
+	if ms.MaxMinutes > 0 {
+		desc += fmt.Sprintf(" [max %g min]", ms.MaxMinutes)
+	}
+	if len(ms.Tags) > 0 {
+		desc += " [tags: " + strings.Join(ms.Tags, ", ") + "]"
+	}
+	if !ms.Enabled {
+		desc += " [disabled]"
+	}
+
+	return fmt.Sprintf("%v x%g: %v", ms.Type, ms.Weight, desc)
+}
+
+// Load returns everything playable from the source. Directories are listed
+// from index if there is one, rather than walked each time, and the local
+// entries of playlists are queued for it to probe. Local clips are
+// adjusted by their sidecars, which may leave some out.
+func (ms MediaSource) Load(extensions []string, index *MediaIndex) ([]MediaItem, error) {
+	var items []MediaItem
+
+	switch ms.Type {
+	case DirectorySource:
+		if index != nil {
+			var err error
+			items, err = index.Items(ms.Path, extensions, ms.Recursive)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		files, err := scanMediaLibrary(ms.Path, extensions, ms.Recursive)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			items = append(items, MediaItem{Location: file})
+		}
+
+	case PlaylistSource:
+		var err error
+		items, err = mediaPlaylists.Items(ms.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if index != nil {
+			locations := make([]string, len(items))
+			for i, item := range items {
+				locations[i] = item.Location
+			}
+			index.Enqueue(locations)
+		}
+
+	case URLListSource:
+		for _, url := range ms.URLs {
+			if url = strings.TrimSpace(url); len(url) > 0 {
+				items = append(items, MediaItem{Location: url})
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown media source type %q", ms.Type)
+	}
+
+	items = mediaSidecars.Apply(items)
+	if len(ms.Tags) > 0 {
+		items = withAnyTag(items, ms.Tags)
+	}
+
+	if len(items) == 0 {
+		return nil, ErrNoMediaFound
+	}
+
+	return items, nil
+}
+
+// Playback returns the playback options for items from the source.
+func (ms MediaSource) Playback() PlaybackOptions {
+	return PlaybackOptions{
+		RandomStart: ms.RandomStart,
+		MaxDuration: time.Duration(ms.MaxMinutes * float64(time.Minute)),
+	}
+}
+
+// parseMediaSources decodes the MediaSources registry value.
+func parseMediaSources(value string) ([]MediaSource, error) {
+	var sources []MediaSource
+	if err := json.Unmarshal([]byte(value), &sources); err != nil {
+		return nil, err
+	}
+
+	if err := validateMediaSources(sources); err != nil {
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+// withAnyTag returns those items tagged with at least one of tags, ignoring
+// case.
+func withAnyTag(items []MediaItem, tags []string) []MediaItem {
+	var ret []MediaItem
+
+	for _, item := range items {
+		for _, tag := range tags {
+			if item.HasTag(tag) {
+				ret = append(ret, item)
+				break
+			}
+		}
+	}
+
+	return ret
+}
+
+func validateMediaSources(sources []MediaSource) error {
+	for i, source := range sources {
+		if source.Weight < 0 {
+			return fmt.Errorf("media source %d has negative weight %g", i, source.Weight)
+		}
+		if source.MaxMinutes < 0 {
+			return fmt.Errorf("media source %d has negative maximum duration %g", i, source.MaxMinutes)
+		}
+	}
+
+	return nil
+}
+
+func formatMediaSources(sources []MediaSource) string {
+	data, err := json.Marshal(sources)
+	if err != nil {
+		// Can't happen: there is nothing in a MediaSource that won't encode.
+		panic(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMediaSources(t *testing.T) {
+	sources, err := parseMediaSources(`[
+		{"type": "directory", "path": "C:\\Videos"},
+		{"type": "directory", "path": "D:\\Flat", "weight": 3, "enabled": false, "recursive": false}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []MediaSource{
+		{Type: DirectorySource, Path: "C:\\Videos", Weight: DefaultSourceWeight, Enabled: true, Recursive: true},
+		{Type: DirectorySource, Path: "D:\\Flat", Weight: 3},
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, sources)
+	}
+
+	if again, err := parseMediaSources(formatMediaSources(sources)); err != nil || !reflect.DeepEqual(again, sources) {
+		t.Errorf("Expected sources to survive formatting, got %+v, %v", again, err)
+	}
+
+	for _, value := range []string{`[{"type": "urls", "weight": -1}]`, `{}`} {
+		if _, err := parseMediaSources(value); err == nil {
+			t.Errorf("Expected %s not to parse", value)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"log"
+	"sort"
+	"strings"
+)
+
+// DisplayMode says how monitors are given videos to play.
+type DisplayMode string
+
+const (
+	// SeparateDisplay plays a different clip on each monitor.
+	SeparateDisplay DisplayMode = "separate"
+	// SpanDisplay plays one clip across all the monitors together, as if
+	// they were one large screen.
+	SpanDisplay DisplayMode = "span"
+	// MirrorDisplay plays the same clip on every monitor, each starting it
+	// at the same time.
+	MirrorDisplay DisplayMode = "mirror"
+)
+
+func parseDisplayMode(value string) DisplayMode {
+	switch DisplayMode(value) {
+	case SpanDisplay, MirrorDisplay:
+		return DisplayMode(value)
+	case SeparateDisplay, "":
+		return SeparateDisplay
+	}
+
+	log.Printf("Unknown display mode %q, using %q", value, SeparateDisplay)
+	return SeparateDisplay
+}
+
+// SpanFit says how a spanned video is sized to the combined desktop.
+type SpanFit string
+
+const (
+	// SpanFitWhole shows the whole picture, with black bars if its shape
+	// differs from the desktop's.
+	SpanFitWhole SpanFit = "fit"
+	// SpanFill crops the picture to the desktop's shape.
+	SpanFill SpanFit = "fill"
+	// SpanStretch distorts the picture to the desktop's shape.
+	SpanStretch SpanFit = "stretch"
+)
+
+func parseSpanFit(value string) SpanFit {
+	switch SpanFit(value) {
+	case SpanFill, SpanStretch:
+		return SpanFit(value)
+	case SpanFitWhole, "":
+		return SpanFitWhole
+	}
+
+	log.Printf("Unknown span fit %q, using %q", value, SpanFitWhole)
+	return SpanFitWhole
+}
+
+// BezelGap is how many pixels' worth of picture the bezels between two
+// adjacent monitors hide.
+type BezelGap struct {
+	Between [2]string `json:"between"`
+	Pixels  int       `json:"pixels"`
+}
+
+// SpanBezels configures bezel compensation: without it, a spanned picture
+// looks broken where it crosses from one monitor to the next, as the bezels
+// take up space the picture doesn't allow for.
+type SpanBezels struct {
+	// Default applies between adjacent monitors not listed in Gaps.
+	Default int        `json:"default"`
+	Gaps    []BezelGap `json:"gaps"`
+}
+
+func parseSpanBezels(value string) (SpanBezels, error) {
+	var bezels SpanBezels
+	if len(value) == 0 {
+		return bezels, nil
+	}
+
+	if err := json.Unmarshal([]byte(value), &bezels); err != nil {
+		return SpanBezels{}, err
+	}
+
+	if bezels.Default < 0 {
+		return SpanBezels{}, fmt.Errorf("negative default bezel gap %d", bezels.Default)
+	}
+	for _, gap := range bezels.Gaps {
+		if gap.Pixels < 0 {
+			return SpanBezels{}, fmt.Errorf("negative bezel gap %d between %v", gap.Pixels, gap.Between)
+		}
+	}
+
+	return bezels, nil
+}
+
+// gap returns the bezel gap between two monitors, by device name.
+func (sb SpanBezels) gap(a, b string) int {
+	for _, gap := range sb.Gaps {
+		if (strings.EqualFold(gap.Between[0], a) && strings.EqualFold(gap.Between[1], b)) ||
+			(strings.EqualFold(gap.Between[0], b) && strings.EqualFold(gap.Between[1], a)) {
+			return gap.Pixels
+		}
+	}
+	return sb.Default
+}
+
+// spanMonitor is a monitor's device name and where it is on the desktop.
+type spanMonitor struct {
+	Name string
+	Rect image.Rectangle
+}
+
+// spanLayout is how a spanned video is laid out across the monitors.
+type spanLayout struct {
+	// Bounds is the union of the monitors, which the video window covers.
+	Bounds image.Rectangle
+	// Canvas is the size of the picture, which is larger than Bounds by the
+	// bezel gaps.
+	Canvas image.Point
+	// Monitors is where each monitor is on the desktop, and Views the part
+	// of the canvas it shows.
+	Monitors []image.Rectangle
+	Views    []image.Rectangle
+}
+
+// newSpanLayout works out the canvas for monitors, inserting the bezel gaps
+// wherever one monitor's edge meets another's. Monitors sharing an edge with
+// several others, as in a grid, use the widest of the gaps along it.
+func newSpanLayout(monitors []spanMonitor, bezels SpanBezels) spanLayout {
+	var layout spanLayout
+	for i, monitor := range monitors {
+		if i == 0 {
+			layout.Bounds = monitor.Rect
+		} else {
+			layout.Bounds = layout.Bounds.Union(monitor.Rect)
+		}
+	}
+
+	// Seams are the edges between monitors, keyed by desktop co-ordinate.
+	columns := map[int]int{}
+	rows := map[int]int{}
+	for _, a := range monitors {
+		for _, b := range monitors {
+			gap := bezels.gap(a.Name, b.Name)
+			if gap == 0 {
+				continue
+			}
+
+			overlapsVertically := a.Rect.Min.Y < b.Rect.Max.Y && b.Rect.Min.Y < a.Rect.Max.Y
+			if a.Rect.Max.X == b.Rect.Min.X && overlapsVertically && gap > columns[b.Rect.Min.X] {
+				columns[b.Rect.Min.X] = gap
+			}
+
+			overlapsHorizontally := a.Rect.Min.X < b.Rect.Max.X && b.Rect.Min.X < a.Rect.Max.X
+			if a.Rect.Max.Y == b.Rect.Min.Y && overlapsHorizontally && gap > rows[b.Rect.Min.Y] {
+				rows[b.Rect.Min.Y] = gap
+			}
+		}
+	}
+
+	layout.Canvas = layout.Bounds.Size().Add(image.Pt(seamsBefore(columns, layout.Bounds.Max.X), seamsBefore(rows, layout.Bounds.Max.Y)))
+
+	for _, monitor := range monitors {
+		shift := image.Pt(seamsBefore(columns, monitor.Rect.Min.X), seamsBefore(rows, monitor.Rect.Min.Y))
+		layout.Monitors = append(layout.Monitors, monitor.Rect)
+		layout.Views = append(layout.Views, monitor.Rect.Sub(layout.Bounds.Min).Add(shift))
+	}
+
+	return layout
+}
+
+// seamsBefore adds up the gaps of the seams at or before position.
+func seamsBefore(seams map[int]int, position int) int {
+	var positions []int
+	for at := range seams {
+		positions = append(positions, at)
+	}
+	sort.Ints(positions)
+
+	var total int
+	for _, at := range positions {
+		if at <= position {
+			total += seams[at]
+		}
+	}
+	return total
+}
+
+// hasBezels reports whether the canvas is larger than the desktop, so that
+// each monitor needs its own view of it.
+func (sl spanLayout) hasBezels() bool {
+	return sl.Canvas != sl.Bounds.Size()
+}
+
+// canvasAspect is the shape of the canvas, as libvlc takes aspect ratios.
+func (sl spanLayout) canvasAspect() string {
+	return reducedRatio(sl.Canvas.X, sl.Canvas.Y)
+}
+
+// pixelAspect is the shape that each pixel of the video window takes up on
+// the canvas. The window is squeezed to fit the desktop, so libvlc has to
+// allow for that as if the monitor had non-square pixels.
+func (sl spanLayout) pixelAspect() string {
+	return reducedRatio(sl.Canvas.X*sl.Bounds.Dy(), sl.Canvas.Y*sl.Bounds.Dx())
+}
+
+// canvasToWindow scales a view of the canvas to the video window, which is
+// squeezed to fit the desktop.
+func (sl spanLayout) canvasToWindow(view image.Rectangle) image.Rectangle {
+	scale := func(p image.Point) image.Point {
+		return image.Pt(p.X*sl.Bounds.Dx()/sl.Canvas.X, p.Y*sl.Bounds.Dy()/sl.Canvas.Y)
+	}
+	return image.Rectangle{Min: scale(view.Min), Max: scale(view.Max)}
+}
+
+func reducedRatio(x, y int) string {
+	a, b := x, y
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a == 0 {
+		return "1:1"
+	}
+	return fmt.Sprintf("%d:%d", x/a, y/a)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSpanLayoutWithoutBezels(t *testing.T) {
+	layout := newSpanLayout([]spanMonitor{
+		{Name: "A", Rect: image.Rect(0, 0, 1920, 1080)},
+		{Name: "B", Rect: image.Rect(1920, 0, 3840, 1080)},
+	}, SpanBezels{})
+
+	if layout.Bounds != image.Rect(0, 0, 3840, 1080) || layout.hasBezels() {
+		t.Errorf("Unexpected layout %+v", layout)
+	}
+	if layout.canvasAspect() != "32:9" {
+		t.Errorf("Expected a 32:9 canvas, got %v", layout.canvasAspect())
+	}
+}
+
+func TestSpanLayoutRow(t *testing.T) {
+	// Monitors to the left of the primary have negative co-ordinates
+	layout := newSpanLayout([]spanMonitor{
+		{Name: "Left", Rect: image.Rect(-1920, 0, 0, 1080)},
+		{Name: "Middle", Rect: image.Rect(0, 0, 1920, 1080)},
+		{Name: "Right", Rect: image.Rect(1920, 0, 3840, 1080)},
+	}, SpanBezels{Default: 40, Gaps: []BezelGap{{Between: [2]string{"right", "middle"}, Pixels: 60}}})
+
+	if layout.Canvas != image.Pt(5860, 1080) {
+		t.Errorf("Expected the canvas to include both gaps, got %v", layout.Canvas)
+	}
+
+	expected := []image.Rectangle{
+		image.Rect(0, 0, 1920, 1080),
+		image.Rect(1960, 0, 3880, 1080),
+		image.Rect(3940, 0, 5860, 1080),
+	}
+	for i, view := range layout.Views {
+		if view != expected[i] {
+			t.Errorf("Expected view %d to be %v, got %v", i, expected[i], view)
+		}
+	}
+
+	if aspect := layout.pixelAspect(); aspect != "293:288" {
+		t.Errorf("Expected window pixels to stand for 5860/5760 canvas pixels across, got %v", aspect)
+	}
+	if window := layout.canvasToWindow(layout.Views[2]); window.Max != image.Pt(5760, 1080) {
+		t.Errorf("Expected the last view to end at the window's corner, got %v", window)
+	}
+}
+
+func TestSpanLayoutGrid(t *testing.T) {
+	layout := newSpanLayout([]spanMonitor{
+		{Name: "TL", Rect: image.Rect(0, 0, 1000, 500)},
+		{Name: "TR", Rect: image.Rect(1000, 0, 2000, 500)},
+		{Name: "BL", Rect: image.Rect(0, 500, 1000, 1000)},
+		{Name: "BR", Rect: image.Rect(1000, 500, 2000, 1000)},
+	}, SpanBezels{Default: 10, Gaps: []BezelGap{{Between: [2]string{"BL", "BR"}, Pixels: 30}}})
+
+	// The widest gap along a seam applies to the whole of it
+	if layout.Canvas != image.Pt(2030, 1010) {
+		t.Errorf("Unexpected canvas %v", layout.Canvas)
+	}
+	if layout.Views[1].Min != image.Pt(1030, 0) || layout.Views[3].Min != image.Pt(1030, 510) {
+		t.Errorf("Unexpected views %v", layout.Views)
+	}
+}
+
+func TestParseSpanSettings(t *testing.T) {
+	if parseDisplayMode("span") != SpanDisplay || parseDisplayMode("mirror") != MirrorDisplay || parseDisplayMode("") != SeparateDisplay || parseDisplayMode("tiled") != SeparateDisplay {
+		t.Errorf("Display modes not parsed as expected")
+	}
+	if parseSpanFit("stretch") != SpanStretch || parseSpanFit("") != SpanFitWhole || parseSpanFit("zoom") != SpanFitWhole {
+		t.Errorf("Span fits not parsed as expected")
+	}
+
+	bezels, err := parseSpanBezels(`{"default": 20, "gaps": [{"between": ["\\\\.\\DISPLAY1", "\\\\.\\DISPLAY2"], "pixels": 35}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bezels.gap(`\\.\display2`, `\\.\DISPLAY1`) != 35 || bezels.gap(`\\.\DISPLAY2`, `\\.\DISPLAY3`) != 20 {
+		t.Errorf("Unexpected bezel gaps %+v", bezels)
+	}
+
+	if _, err := parseSpanBezels(`{"default": -5}`); err == nil {
+		t.Errorf("Expected an error for a negative gap")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"image"
+	"log"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/lxn/walk"
+	"github.com/lxn/walk/declarative"
+	"github.com/lxn/win"
+)
+
+// With bezel compensation, a spanned video can't simply be shown in one
+// window covering the desktop, as each monitor must skip the part of the
+// picture hidden behind the bezels. Instead the video window sits beneath a
+// window on each monitor, which shows its part of the video through a DWM
+// thumbnail: a live, scaled copy of part of another window.
+
+const SpanViewWidgetWindowClass = "Span View Widget Class"
+
+var (
+	libdwmapi                    = windows.NewLazySystemDLL("dwmapi.dll")
+	dwmRegisterThumbnail         = libdwmapi.NewProc("DwmRegisterThumbnail")
+	dwmUnregisterThumbnail       = libdwmapi.NewProc("DwmUnregisterThumbnail")
+	dwmUpdateThumbnailProperties = libdwmapi.NewProc("DwmUpdateThumbnailProperties")
+)
+
+const (
+	dwmTnpRectDestination      = 0x1
+	dwmTnpRectSource           = 0x2
+	dwmTnpVisible              = 0x8
+	dwmTnpSourceClientAreaOnly = 0x10
+)
+
+type dwmThumbnailProperties struct {
+	Flags                uint32
+	Destination          win.RECT
+	Source               win.RECT
+	Opacity              byte
+	Visible              win.BOOL
+	SourceClientAreaOnly win.BOOL
+}
+
+// spanView is a window on one monitor showing that monitor's part of a
+// spanned video.
+type spanView struct {
+	mainWindow *walk.MainWindow
+	widget     *spanViewWidget
+}
+
+// spanViewWidget fills a span view's window, so that it sees the user's
+// input, and owns the thumbnail drawn over it.
+type spanViewWidget struct {
+	walk.WidgetBase
+
+	finishCallback func()
+	cursorPos      win.POINT
+	thumbnail      uintptr
+}
+
+// newSpanView creates a window covering bounds, which shows the part of
+// source's client area given by view. finish is called when the user comes
+// back.
+func newSpanView(bounds image.Rectangle, source win.HWND, view image.Rectangle, finish func()) (*spanView, error) {
+	sv := &spanView{}
+
+	if err := (declarative.MainWindow{
+		AssignTo: &sv.mainWindow,
+		Title:    "Video span window",
+		Layout: declarative.VBox{
+			MarginsZero: true,
+			SpacingZero: true,
+		},
+		Bounds: declarative.Rectangle{
+			X:      bounds.Min.X,
+			Y:      bounds.Min.Y,
+			Width:  bounds.Dx(),
+			Height: bounds.Dy(),
+		},
+		Background: declarative.SolidColorBrush{
+			Color: walk.RGB(0, 0, 0),
+		},
+	}).Create(); err != nil {
+		return nil, err
+	}
+
+	sv.widget = &spanViewWidget{finishCallback: finish}
+	if err := walk.InitWidget(sv.widget, sv.mainWindow, SpanViewWidgetWindowClass, win.WS_VISIBLE, 0); err != nil {
+		return nil, err
+	}
+	if !win.GetCursorPos(&sv.widget.cursorPos) {
+		log.Panic("GetCursorPos failed")
+	}
+
+	sv.mainWindow.SetFullscreen(true)
+
+	// Keep the views in front of the video window they show.
+	win.SetWindowPos(sv.mainWindow.Handle(), win.HWND_TOPMOST, 0, 0, 0, 0,
+		win.SWP_NOMOVE|win.SWP_NOSIZE|win.SWP_NOACTIVATE)
+
+	if err := sv.widget.showThumbnail(sv.mainWindow.Handle(), source, view); err != nil {
+		sv.mainWindow.Dispose()
+		return nil, err
+	}
+
+	return sv, nil
+}
+
+// showThumbnail fills destination with the view of source.
+func (svw *spanViewWidget) showThumbnail(destination, source win.HWND, view image.Rectangle) error {
+	var client win.RECT
+	win.GetClientRect(destination, &client)
+
+	if hr, _, _ := dwmRegisterThumbnail.Call(uintptr(destination), uintptr(source), uintptr(unsafe.Pointer(&svw.thumbnail))); win.FAILED(win.HRESULT(hr)) {
+		return windows.Errno(hr)
+	}
+
+	properties := dwmThumbnailProperties{
+		Flags:                dwmTnpRectDestination | dwmTnpRectSource | dwmTnpVisible | dwmTnpSourceClientAreaOnly,
+		Destination:          client,
+		Source:               win.RECT{Left: int32(view.Min.X), Top: int32(view.Min.Y), Right: int32(view.Max.X), Bottom: int32(view.Max.Y)},
+		Visible:              win.TRUE,
+		SourceClientAreaOnly: win.TRUE,
+	}
+	if hr, _, _ := dwmUpdateThumbnailProperties.Call(svw.thumbnail, uintptr(unsafe.Pointer(&properties))); win.FAILED(win.HRESULT(hr)) {
+		return windows.Errno(hr)
+	}
+
+	return nil
+}
+
+func (sv *spanView) Close() {
+	if sv.widget.thumbnail != 0 {
+		dwmUnregisterThumbnail.Call(sv.widget.thumbnail)
+		sv.widget.thumbnail = 0
+	}
+	sv.mainWindow.Close()
+}
+
+func (*spanViewWidget) CreateLayoutItem(ctx *walk.LayoutContext) walk.LayoutItem {
+	return &vlcVideoWidgetLayoutItem{idealSize: walk.SizeFrom96DPI(walk.Size{Width: 150, Height: 150}, ctx.DPI())}
+}
+
+func (svw *spanViewWidget) WndProc(hwnd win.HWND, msg uint32, wParam, lParam uintptr) uintptr {
+	if userIsBack(msg, wParam, lParam, svw.cursorPos) {
+		svw.finishCallback()
+	}
+
+	if msg == win.WM_SETCURSOR {
+		return 1
+	}
+
+	return svw.WidgetBase.WndProc(hwnd, msg, wParam, lParam)
+}
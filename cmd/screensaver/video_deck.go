@@ -0,0 +1,116 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	vlc "github.com/sammydre/golang-video-screensaver/vlcwrap"
+)
+
+// videoDeck is one of a loop's two players. Each renders into its own
+// window, so that while one plays, the other can open and buffer the next
+// clip ready to be switched to without a gap.
+type videoDeck struct {
+	player vlc.MediaPlayer
+	window deckWindow
+	media  vlc.MediaHandle
+	// item is the clip loaded into the player. It has no location while the
+	// fallback is showing.
+	item        MediaItem
+	seekPending bool
+	startOffset time.Duration
+	// ready means item is loaded and paused, waiting for its turn.
+	ready bool
+	// events is the player's event source, through the loop's event bus.
+	events                  vlc.EventSource
+	endReachedEventId       vlc.EventID
+	encounteredErrorEventId vlc.EventID
+}
+
+// deckWindow is the window a deck's player renders into.
+type deckWindow interface {
+	// show brings the window to the front.
+	show()
+	hide()
+	// setAlpha sets how opaque a layered window is.
+	setAlpha(alpha byte)
+	// resize fits the window to its parent.
+	resize(width, height int32)
+	destroy()
+}
+
+// load puts item into the player, replacing whatever it had before. Paused
+// clips are opened and buffered when played, but not started.
+func (deck *videoDeck) load(item MediaItem, paused bool) error {
+	media, err := deck.player.Load(item.Location, !isMediaURL(item.Location))
+	if err != nil {
+		return err
+	}
+
+	options := item.Playback.mediaOptions()
+	if paused {
+		options = append(options, ":start-paused")
+	}
+	for _, option := range options {
+		if err := media.AddOption(option); err != nil {
+			log.Printf("Unable to apply %v to %v: %v", option, item.Location, err)
+		}
+	}
+
+	deck.replaceMedia(media)
+	deck.item = item
+	deck.ready = paused
+	deck.seekPending = item.Playback.RandomStart
+	// With an in point, libvlc starts the clip there, not at the beginning.
+	deck.startOffset = item.Playback.In
+
+	return deck.player.Play()
+}
+
+// replaceMedia releases our reference to the media we last loaded, which
+// the player has now let go of too.
+func (deck *videoDeck) replaceMedia(media vlc.MediaHandle) {
+	if deck.media != nil {
+		deck.media.Release()
+	}
+	deck.media = media
+}
+
+// stop stops the player and forgets its clip.
+func (deck *videoDeck) stop() {
+	deck.player.Stop()
+	deck.item = MediaItem{}
+	deck.ready = false
+	deck.seekPending = false
+}
+
+func (deck *videoDeck) show() {
+	deck.window.show()
+}
+
+func (deck *videoDeck) hide() {
+	deck.window.hide()
+}
+
+func (deck *videoDeck) setAlpha(alpha byte) {
+	deck.window.setAlpha(alpha)
+}
+
+func (deck *videoDeck) resize(width, height int32) {
+	deck.window.resize(width, height)
+}
+
+func (deck *videoDeck) release() {
+	if deck.player != nil {
+		if deck.events != nil {
+			deck.events.Detach(deck.endReachedEventId, deck.encounteredErrorEventId)
+		}
+
+		deck.player.Stop()
+		deck.replaceMedia(nil)
+		deck.player.Release()
+		deck.player = nil
+	}
+
+	deck.window.destroy()
+}
@@ -5,34 +5,34 @@ import (
 
 	"github.com/lxn/walk"
 	"github.com/lxn/win"
-	vlc "github.com/sammydre/golang-video-screensaver/vlcwrap"
 )
 
+// VlcVideoWidget plays clips through a playbackLoop, filling its window with
+// them, and finishes the screensaver when the user comes back.
 type VlcVideoWidget struct {
 	walk.WidgetBase
+	*playbackLoop
 
 	screenSaverFinishCallback func()
-	nextMediaFileCallback     func() string
-	synchroniseCallback       func(func())
 	cursorPos                 win.POINT
-	videoPlayer               *vlc.Player
-	endReachedEventId         vlc.EventID
 	hwndForVlc                win.HWND
 }
 
 const VlcVideoWidgetWindowClass = "VLC Video Widget Class"
 
-func NewVlcVideoWidget(parent walk.Container, finishCallback func(), mediaPathCallback func() string, synchroniseCallback func(func())) (*VlcVideoWidget, error) {
+func NewVlcVideoWidget(parent walk.Container, finishCallback func(), mediaPathCallback func(bool) (MediaItem, error), mediaStartedCallback func(MediaItem), mediaFailedCallback func(string, error), synchroniseCallback func(func())) (*VlcVideoWidget, error) {
 	w := new(VlcVideoWidget)
 	w.screenSaverFinishCallback = finishCallback
-	w.nextMediaFileCallback = mediaPathCallback
-	w.synchroniseCallback = synchroniseCallback
+	w.playbackLoop = newPlaybackLoop(w.createDeck, mediaPathCallback, mediaStartedCallback, mediaFailedCallback, synchroniseCallback)
+	w.playingCallback = func() {
+		win.SetCursor(0)
+	}
 
 	if err := walk.InitWidget(
 		w,
 		parent,
 		VlcVideoWidgetWindowClass,
-		win.WS_VISIBLE,
+		win.WS_VISIBLE|win.WS_CLIPCHILDREN,
 		0); err != nil {
 		return nil, err
 	}
@@ -53,100 +53,22 @@ func NewVlcVideoWidget(parent walk.Container, finishCallback func(), mediaPathCa
 	return w, nil
 }
 
-func NewPreviewVlcVideoWidget(parent win.HWND, mediaPathCallback func() string, synchroniseCallback func(func())) (*VlcVideoWidget, error) {
+func NewPreviewVlcVideoWidget(parent win.HWND, mediaPathCallback func(bool) (MediaItem, error), mediaStartedCallback func(MediaItem), mediaFailedCallback func(string, error), synchroniseCallback func(func())) (*VlcVideoWidget, error) {
 	w := new(VlcVideoWidget)
 	w.screenSaverFinishCallback = func() {}
-	w.nextMediaFileCallback = mediaPathCallback
-	w.synchroniseCallback = synchroniseCallback
+	w.playbackLoop = newPlaybackLoop(w.createDeck, mediaPathCallback, mediaStartedCallback, mediaFailedCallback, synchroniseCallback)
 	w.hwndForVlc = parent
 
 	return w, nil
 }
 
 func (vvw *VlcVideoWidget) SetupVlcPlayer() {
-	var err error
-
-	log.Print("Creating and initialising VLC player...")
-
-	vvw.videoPlayer, err = vlc.NewPlayer()
-	if err != nil {
-		log.Panic(err)
-	}
-
-	err = vvw.videoPlayer.SetHWND(uintptr(vvw.hwndForVlc))
-	if err != nil {
-		log.Panic(err)
-	}
-
-	err = vvw.videoPlayer.SetKeyInput(false)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	err = vvw.videoPlayer.SetMouseInput(false)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	err = vvw.videoPlayer.SetAudioOutput("adummy")
-	if err != nil {
-		log.Print(err)
-	}
-
-	err = vvw.videoPlayer.SetMute(true)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	mediaFileName := vvw.nextMediaFileCallback()
-
-	_, err = vvw.videoPlayer.LoadMediaFromPath(mediaFileName)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	manager, err := vvw.videoPlayer.EventManager()
-	if err != nil {
-		log.Panic(err)
-	}
-
-	endReachedCallback := func(event vlc.Event, userData interface{}) {
-		// This callback is called from a somewhat uncertain context. I don't think
-		// we can safely call vlc functions in this state? (Maybe its not re-entrant?)
-		vvw.synchroniseCallback(func() {
-			mediaFileName := vvw.nextMediaFileCallback()
-			vvw.videoPlayer.LoadMediaFromPath(mediaFileName)
-			vvw.videoPlayer.Play()
-			win.SetCursor(0)
-		})
-	}
-
-	vvw.endReachedEventId, err = manager.Attach(vlc.MediaPlayerEndReached, endReachedCallback, nil)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	log.Print("VLC player initialised, playing")
-	vvw.videoPlayer.Play()
+	vvw.start()
 }
 
-func (vvw *VlcVideoWidget) Deinit() {
-	if vvw.videoPlayer != nil {
-		manager, err := vvw.videoPlayer.EventManager()
-		if err != nil {
-			log.Panic(err)
-		}
-
-		manager.Detach(vvw.endReachedEventId)
-
-		if media, _ := vvw.videoPlayer.Media(); media != nil {
-			media.Release()
-		}
-
-		vvw.videoPlayer.Stop()
-		vvw.videoPlayer.Release()
-		vvw.videoPlayer = nil
-	}
+// createDeck creates a deck rendering into a child of our window.
+func (vvw *VlcVideoWidget) createDeck(layered bool) (*videoDeck, error) {
+	return newVideoDeck(vvw.hwndForVlc, layered)
 }
 
 func (*VlcVideoWidget) CreateLayoutItem(ctx *walk.LayoutContext) walk.LayoutItem {
@@ -166,23 +88,34 @@ func (li *vlcVideoWidgetLayoutItem) IdealSize() walk.Size {
 	return li.idealSize
 }
 
-func (w *VlcVideoWidget) WndProc(hwnd win.HWND, msg uint32, wParam, lParam uintptr) uintptr {
+// userIsBack reports whether a window message means the screensaver should
+// finish: the user has pressed something, or moved the mouse from where it
+// was when we started.
+func userIsBack(msg uint32, wParam, lParam uintptr, cursorPos win.POINT) bool {
 	switch msg {
 	case win.WM_NCACTIVATE, win.WM_ACTIVATE, win.WM_ACTIVATEAPP:
-		if wParam == 0 {
-			w.screenSaverFinishCallback()
-		}
+		return wParam == 0
 	case win.WM_LBUTTONDOWN, win.WM_RBUTTONDOWN, win.WM_MBUTTONDOWN, win.WM_XBUTTONDOWN, win.WM_KEYDOWN, win.WM_KEYUP, win.WM_SYSKEYDOWN:
-		w.screenSaverFinishCallback()
+		return true
 	case win.WM_MOUSEMOVE:
 		var point = win.POINT{
 			X: int32(win.GET_X_LPARAM(lParam)),
 			Y: int32(win.GET_Y_LPARAM(lParam))}
-		if point.X != w.cursorPos.X || point.Y != w.cursorPos.Y {
-			w.screenSaverFinishCallback()
-		}
+		return point.X != cursorPos.X || point.Y != cursorPos.Y
+	}
+	return false
+}
+
+func (w *VlcVideoWidget) WndProc(hwnd win.HWND, msg uint32, wParam, lParam uintptr) uintptr {
+	if userIsBack(msg, wParam, lParam, w.cursorPos) {
+		w.screenSaverFinishCallback()
+	}
+
+	switch msg {
 	case win.WM_SETCURSOR:
 		return 1
+	case win.WM_SIZE:
+		w.resize(int32(win.LOWORD(uint32(lParam))), int32(win.HIWORD(uint32(lParam))))
 	}
 
 	return w.WidgetBase.WndProc(hwnd, msg, wParam, lParam)
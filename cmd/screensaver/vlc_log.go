@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+
+	vlc "github.com/sammydre/golang-video-screensaver/vlcwrap"
+)
+
+// DefaultVlcLogFilter passes on only libvlc's errors, as its warnings are
+// many and mostly harmless.
+var DefaultVlcLogFilter = vlc.LogFilter{Level: vlc.LogError}
+
+// parseVlcLogFilter reads which libvlc messages to log, as stored in the
+// registry: a level, optionally followed by levels for particular modules,
+// such as "warning,avcodec=error".
+func parseVlcLogFilter(value string) vlc.LogFilter {
+	if len(value) == 0 {
+		return DefaultVlcLogFilter
+	}
+
+	filter, err := vlc.ParseLogFilter(value)
+	if err != nil {
+		log.Printf("Invalid libvlc log level %q, logging errors only: %v", value, err)
+		return DefaultVlcLogFilter
+	}
+
+	return filter
+}
+
+// logVlcMessage writes a libvlc message to our log.
+func logVlcMessage(msg vlc.LogMessage) {
+	log.Printf("libvlc: %v", msg)
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	vlc "github.com/sammydre/golang-video-screensaver/vlcwrap"
+)
+
+func TestParseVlcLogFilter(t *testing.T) {
+	if got := parseVlcLogFilter(""); got.Level != vlc.LogError || got.Modules != nil {
+		t.Errorf("Expected errors only by default, got %+v", got)
+	}
+	if got := parseVlcLogFilter("loud"); got.Level != vlc.LogError {
+		t.Errorf("Expected errors only for an invalid level, got %+v", got)
+	}
+
+	got := parseVlcLogFilter("warning,avcodec=error")
+	if got.Level != vlc.LogWarning || got.Modules["avcodec"] != vlc.LogError {
+		t.Errorf("Unexpected filter %+v", got)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultPollInterval is how often directories that can't be watched are
+// rescanned.
+const DefaultPollInterval = 5 * time.Minute
+
+// watchSettleDelay is how long a path must go without further events before
+// we look at it. Copying a large clip produces a stream of writes, and we
+// don't want to index or play it until it is complete.
+const watchSettleDelay = 2 * time.Second
+
+// parsePollInterval decodes the PollInterval registry value, in seconds.
+// Zero disables polling.
+func parsePollInterval(value string) time.Duration {
+	if len(value) == 0 {
+		return DefaultPollInterval
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		log.Printf("Invalid poll interval %q, using %v", value, DefaultPollInterval)
+		return DefaultPollInterval
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// watchedRoot is a directory source the library watcher looks after.
+type watchedRoot struct {
+	Path      string
+	Recursive bool
+}
+
+// libraryWatcher applies changes to the directories among the media sources
+// to the index as they happen, so that new clips are played and deleted ones
+// aren't, without restarting. Directories on network shares, or which can't
+// be watched for any other reason, are rescanned periodically instead.
+type libraryWatcher struct {
+	index        *MediaIndex
+	extensions   []string
+	pollInterval time.Duration
+
+	watcher *fsnotify.Watcher
+	roots   []watchedRoot
+	polled  []watchedRoot
+
+	// pending is when each changed path last had an event.
+	pending map[string]time.Time
+}
+
+// Watch keeps the index in step with the directories among sources until
+// the index is closed. Setting up the watches means walking every directory,
+// so that is done in the background too.
+func (mi *MediaIndex) Watch(sources []MediaSource, extensions []string, pollInterval time.Duration) {
+	lw := &libraryWatcher{
+		index:        mi,
+		extensions:   extensions,
+		pollInterval: pollInterval,
+		pending:      map[string]time.Time{},
+	}
+
+	for _, source := range sources {
+		if source.Type == DirectorySource && source.Enabled {
+			lw.roots = append(lw.roots, watchedRoot{Path: source.Path, Recursive: source.Recursive})
+		}
+	}
+	if len(lw.roots) == 0 {
+		return
+	}
+
+	mi.background.Add(1)
+	go func() {
+		defer mi.background.Done()
+		lw.start()
+		lw.run()
+	}()
+}
+
+// start sets up watches on every root, or failing that, polling.
+func (lw *libraryWatcher) start() {
+	var err error
+	if lw.watcher, err = fsnotify.NewWatcher(); err != nil {
+		log.Printf("Unable to watch media directories, polling instead: %v", err)
+	}
+
+	for _, root := range lw.roots {
+		if lw.watcher == nil || isNetworkPath(root.Path) {
+			lw.polled = append(lw.polled, root)
+			continue
+		}
+
+		if err := lw.watchTree(root.Path, root.Recursive); err != nil {
+			log.Printf("Unable to watch %v, polling instead: %v", root.Path, err)
+			lw.polled = append(lw.polled, root)
+		}
+	}
+}
+
+// watchTree adds a watch for dir and, if recursive, every directory under it.
+// fsnotify only reports changes directly within a watched directory.
+func (lw *libraryWatcher) watchTree(dir string, recursive bool) error {
+	if !recursive {
+		return lw.watcher.Add(dir)
+	}
+
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != dir && isHiddenOrSystem(entry) {
+			return filepath.SkipDir
+		}
+
+		if err := lw.watcher.Add(path); err != nil {
+			if path == dir {
+				return err
+			}
+			log.Printf("Unable to watch %v: %v", path, err)
+		}
+		return nil
+	})
+}
+
+// inRecursiveRoot reports whether path is under a root whose subfolders are
+// included.
+func (lw *libraryWatcher) inRecursiveRoot(path string) bool {
+	for _, root := range lw.roots {
+		if root.Recursive && inRoot(path, root.Path, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func (lw *libraryWatcher) run() {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if lw.watcher != nil {
+		defer lw.watcher.Close()
+		events, errs = lw.watcher.Events, lw.watcher.Errors
+	}
+
+	settle := time.NewTicker(watchSettleDelay / 2)
+	defer settle.Stop()
+
+	var poll <-chan time.Time
+	if len(lw.polled) > 0 && lw.pollInterval > 0 {
+		ticker := time.NewTicker(lw.pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-lw.index.stop:
+			return
+
+		case event := <-events:
+			lw.pending[event.Name] = time.Now()
+
+			// New directories need watching themselves, if they are under
+			// a root whose subfolders are played. The directory is added to
+			// the index once it has settled.
+			if event.Op&fsnotify.Create != 0 && lw.inRecursiveRoot(event.Name) {
+				lw.watchTree(event.Name, true)
+			}
+
+		case err := <-errs:
+			// Most likely the event buffer overflowed, so we may have missed
+			// anything: rescan the lot.
+			log.Printf("Error watching media directories, rescanning: %v", err)
+			for _, root := range lw.roots {
+				lw.rescan(root)
+			}
+			lw.index.probePending()
+
+		case now := <-settle.C:
+			lw.applySettled(now)
+
+		case <-poll:
+			for _, root := range lw.polled {
+				lw.rescan(root)
+			}
+			lw.index.probePending()
+		}
+	}
+}
+
+func (lw *libraryWatcher) rescan(root watchedRoot) {
+	if err := lw.index.Rescan(root.Path, lw.extensions, root.Recursive); err != nil {
+		log.Printf("Error rescanning %v: %v", root.Path, err)
+	}
+}
+
+// applySettled updates the index with every path that has gone quiet, then
+// probes whatever is new. Changed sidecars are reread.
+func (lw *libraryWatcher) applySettled(now time.Time) {
+	var applied bool
+
+	for path, last := range lw.pending {
+		if now.Sub(last) < watchSettleDelay {
+			continue
+		}
+
+		delete(lw.pending, path)
+		if isSidecarFile(path) {
+			mediaSidecars.Reload(filepath.Dir(path))
+			continue
+		}
+		lw.index.Update(path)
+		applied = true
+	}
+
+	if applied {
+		lw.index.Save()
+		lw.index.probePending()
+	}
+}
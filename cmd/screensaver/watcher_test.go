@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// waitForItems polls the index until the listing of root is the expected
+// files, failing the test if it isn't within a few seconds.
+func waitForItems(t *testing.T, mi *MediaIndex, root string, expected ...string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * watchSettleDelay)
+	for {
+		items, _ := mi.Items(root, DefaultMediaExtensions, true)
+
+		var locations []string
+		for _, item := range items {
+			locations = append(locations, item.Location)
+		}
+
+		var want []string
+		for _, name := range expected {
+			want = append(want, filepath.Join(root, name))
+		}
+
+		if reflect.DeepEqual(locations, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %v, got %v", want, locations)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestLibraryWatcher(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "a.mp4"), "a")
+
+	mi := LoadMediaIndex(filepath.Join(t.TempDir(), "index.json"))
+	defer mi.Close()
+
+	waitForItems(t, mi, root, "a.mp4")
+	mi.Watch([]MediaSource{newMediaSource(root)}, DefaultMediaExtensions, 0)
+
+	// Give the watcher a moment to set up its watches.
+	time.Sleep(100 * time.Millisecond)
+
+	// Added, including in a new subdirectory
+	writeTestFile(t, filepath.Join(root, "b.mkv"), "b")
+	if err := os.MkdirAll(filepath.Join(root, "new", "deeper"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(root, "new", "deeper", "c.mov"), "c")
+	writeTestFile(t, filepath.Join(root, "notes.txt"), "not media")
+	waitForItems(t, mi, root, "a.mp4", "b.mkv", filepath.Join("new", "deeper", "c.mov"))
+
+	// Renamed
+	if err := os.Rename(filepath.Join(root, "b.mkv"), filepath.Join(root, "renamed.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	waitForItems(t, mi, root, "a.mp4", filepath.Join("new", "deeper", "c.mov"), "renamed.mkv")
+
+	// Deleted, including a whole directory
+	if err := os.Remove(filepath.Join(root, "a.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(root, "new")); err != nil {
+		t.Fatal(err)
+	}
+	waitForItems(t, mi, root, "renamed.mkv")
+}
+
+func TestLibraryWatcherInRecursiveRoot(t *testing.T) {
+	flat, deep := t.TempDir(), t.TempDir()
+	lw := &libraryWatcher{roots: []watchedRoot{{Path: flat}, {Path: deep, Recursive: true}}}
+
+	for path, expected := range map[string]bool{
+		filepath.Join(flat, "new"):           false,
+		filepath.Join(deep, "new"):           true,
+		filepath.Join(deep, "new", "deeper"): true,
+		deep:                                 false,
+		t.TempDir():                          false,
+	} {
+		if got := lw.inRecursiveRoot(path); got != expected {
+			t.Errorf("inRecursiveRoot(%v) = %v, expected %v", path, got, expected)
+		}
+	}
+}
+
+func TestMediaIndexUpdate(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "a.mp4"), "a")
+
+	mi := LoadMediaIndex(filepath.Join(t.TempDir(), "index.json"))
+	if err := mi.Rescan(root, DefaultMediaExtensions, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Outside any indexed root
+	other := filepath.Join(t.TempDir(), "other.mp4")
+	writeTestFile(t, other, "other")
+	mi.Update(other)
+	if _, ok := mi.entries[other]; ok {
+		t.Errorf("Indexed a file outside every root")
+	}
+
+	writeTestFile(t, filepath.Join(root, "sub", "b.mp4"), "b")
+	mi.Update(filepath.Join(root, "sub"))
+	if items, _ := mi.Items(root, DefaultMediaExtensions, true); len(items) != 2 {
+		t.Errorf("Expected a new directory's contents to be indexed, got %v", items)
+	}
+
+	os.RemoveAll(filepath.Join(root, "sub"))
+	mi.Update(filepath.Join(root, "sub"))
+	if items, _ := mi.Items(root, DefaultMediaExtensions, true); len(items) != 1 {
+		t.Errorf("Expected a removed directory's contents to be dropped, got %v", items)
+	}
+}
+
+func TestParsePollInterval(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"":    DefaultPollInterval,
+		"60":  time.Minute,
+		"0":   0,
+		"-1":  DefaultPollInterval,
+		"abc": DefaultPollInterval,
+	} {
+		if got := parsePollInterval(value); got != expected {
+			t.Errorf("parsePollInterval(%q) = %v, expected %v", value, got, expected)
+		}
+	}
+}
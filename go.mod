@@ -4,6 +4,7 @@ go 1.16
 
 require (
 	github.com/akavel/rsrc v0.10.2 // indirect
+	github.com/fsnotify/fsnotify v1.5.1
 	github.com/lxn/walk v0.0.0-20210112085537-c389da54e794
 	github.com/lxn/win v0.0.0-20210218163916-a377121e959e
 	golang.org/x/sys v0.0.0-20220209214540-3681064d5158
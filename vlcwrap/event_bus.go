@@ -0,0 +1,243 @@
+package vlcwrap
+
+import "sync"
+
+// libvlc calls event callbacks on its own threads, often with a player's
+// locks held, so a callback that calls back into the player can deadlock.
+// An EventBus keeps callbacks off those threads: events are only queued as
+// they arrive, and delivered later through a Dispatcher, on whichever thread
+// it runs things on.
+
+// Dispatcher runs functions on the thread events should be handled on. It
+// must not run them before Dispatch returns, as it may be called from
+// whatever thread an event arrived on.
+type Dispatcher interface {
+	Dispatch(f func())
+}
+
+// DispatcherFunc adapts a function, such as a walk window's Synchronize, to
+// a Dispatcher.
+type DispatcherFunc func(func())
+
+func (df DispatcherFunc) Dispatch(f func()) {
+	df(f)
+}
+
+// QueueDispatcher is a Dispatcher for threads running their own loop, such
+// as a hand-written message loop. Functions are queued until the loop calls
+// RunPending, which it should do whenever Ready is signalled or Notify is
+// called.
+type QueueDispatcher struct {
+	// Notify, if set, is called whenever a function is queued, from the
+	// thread queuing it, to wake a loop that can't wait on Ready.
+	Notify func()
+
+	sync.Mutex
+	queue []func()
+	ready chan struct{}
+}
+
+func NewQueueDispatcher(notify func()) *QueueDispatcher {
+	return &QueueDispatcher{Notify: notify, ready: make(chan struct{}, 1)}
+}
+
+func (qd *QueueDispatcher) Dispatch(f func()) {
+	qd.Lock()
+	qd.queue = append(qd.queue, f)
+	qd.Unlock()
+
+	select {
+	case qd.ready <- struct{}{}:
+	default:
+	}
+	if qd.Notify != nil {
+		qd.Notify()
+	}
+}
+
+// Ready is signalled when there are functions waiting to be run.
+func (qd *QueueDispatcher) Ready() <-chan struct{} {
+	return qd.ready
+}
+
+// RunPending runs the functions queued so far, in order, along with any they
+// queue in turn.
+func (qd *QueueDispatcher) RunPending() {
+	for {
+		qd.Lock()
+		queue := qd.queue
+		qd.queue = nil
+		qd.Unlock()
+
+		if len(queue) == 0 {
+			return
+		}
+		for _, f := range queue {
+			f()
+		}
+	}
+}
+
+// queuedEvent is an event waiting to be handed to the dispatcher.
+type queuedEvent struct {
+	attachment *attachment
+	event      Event
+	payload    interface{}
+}
+
+// EventBus delivers events from event sources through a Dispatcher, in the
+// order they arrived. Callbacks attached through it can use the player
+// freely, and are never called once detached, even for events that arrived
+// beforehand.
+type EventBus struct {
+	dispatcher Dispatcher
+
+	sync.Mutex
+	cond    *sync.Cond
+	queue   []queuedEvent
+	handing int
+	closed  bool
+}
+
+// NewEventBus creates a bus delivering events through dispatcher. It must be
+// closed once finished with.
+func NewEventBus(dispatcher Dispatcher) *EventBus {
+	eb := &EventBus{dispatcher: dispatcher}
+	eb.cond = sync.NewCond(&eb.Mutex)
+	go eb.run()
+	return eb
+}
+
+// run hands queued events to the dispatcher. It is done on a goroutine of
+// its own, as the dispatcher may block.
+func (eb *EventBus) run() {
+	eb.Lock()
+	defer eb.Unlock()
+
+	for {
+		for len(eb.queue) == 0 && !eb.closed {
+			eb.cond.Wait()
+		}
+		if eb.closed {
+			return
+		}
+
+		queue := eb.queue
+		eb.queue = nil
+		eb.handing = len(queue)
+		eb.Unlock()
+
+		for _, qe := range queue {
+			qe := qe
+			eb.dispatcher.Dispatch(func() {
+				qe.attachment.deliver(qe.event, qe.payload)
+			})
+		}
+
+		eb.Lock()
+		eb.handing = 0
+		eb.cond.Broadcast()
+	}
+}
+
+func (eb *EventBus) push(qe queuedEvent) {
+	eb.Lock()
+	defer eb.Unlock()
+
+	if eb.closed {
+		return
+	}
+	eb.queue = append(eb.queue, qe)
+	eb.cond.Broadcast()
+}
+
+// Flush waits until every event that has arrived so far has been handed to
+// the dispatcher, though not necessarily delivered by it.
+func (eb *EventBus) Flush() {
+	eb.Lock()
+	defer eb.Unlock()
+
+	for (len(eb.queue) > 0 || eb.handing > 0) && !eb.closed {
+		eb.cond.Wait()
+	}
+}
+
+// Close stops the bus. Events that haven't been handed to the dispatcher yet
+// are dropped, as are any arriving later.
+func (eb *EventBus) Close() {
+	eb.Lock()
+	defer eb.Unlock()
+
+	eb.closed = true
+	eb.queue = nil
+	eb.cond.Broadcast()
+}
+
+// Source returns an EventSource attaching callbacks to source's events,
+// which are delivered through the bus.
+func (eb *EventBus) Source(source EventSource) EventSource {
+	return &busSource{bus: eb, source: source, attachments: map[EventID]*attachment{}}
+}
+
+type busSource struct {
+	bus    *EventBus
+	source EventSource
+
+	sync.Mutex
+	attachments map[EventID]*attachment
+}
+
+// attachment is a callback attached through the bus.
+type attachment struct {
+	callback EventCallback
+	userData interface{}
+
+	sync.Mutex
+	detached bool
+}
+
+func (a *attachment) deliver(event Event, payload interface{}) {
+	a.Lock()
+	detached := a.detached
+	a.Unlock()
+
+	if !detached {
+		a.callback(event, payload, a.userData)
+	}
+}
+
+func (bs *busSource) Attach(event Event, callback EventCallback, userData interface{}) (EventID, error) {
+	if callback == nil {
+		return 0, ErrInvalidEventCallback
+	}
+
+	// No lock is held while attaching, as libvlc holds its own while calling
+	// callbacks.
+	a := &attachment{callback: callback, userData: userData}
+	id, err := bs.source.Attach(event, func(event Event, payload interface{}, _ interface{}) {
+		bs.bus.push(queuedEvent{attachment: a, event: event, payload: payload})
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	bs.Lock()
+	bs.attachments[id] = a
+	bs.Unlock()
+	return id, nil
+}
+
+func (bs *busSource) Detach(eventIDs ...EventID) {
+	bs.Lock()
+	for _, id := range eventIDs {
+		if a, ok := bs.attachments[id]; ok {
+			a.Lock()
+			a.detached = true
+			a.Unlock()
+			delete(bs.attachments, id)
+		}
+	}
+	bs.Unlock()
+
+	bs.source.Detach(eventIDs...)
+}
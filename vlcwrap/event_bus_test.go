@@ -0,0 +1,128 @@
+package vlcwrap_test
+
+import (
+	"testing"
+	"time"
+
+	vlc "github.com/sammydre/golang-video-screensaver/vlcwrap"
+	"github.com/sammydre/golang-video-screensaver/vlcwrap/vlcfake"
+)
+
+func newBusPlayer(t *testing.T) (*vlc.EventBus, *vlc.QueueDispatcher, *vlcfake.Player, vlc.EventSource) {
+	library := vlcfake.NewLibrary()
+	library.Add("a.mp4", vlcfake.Clip{Length: time.Second})
+	player := library.NewPlayer()
+	if _, err := player.Load("a.mp4", true); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := vlc.NewQueueDispatcher(nil)
+	bus := vlc.NewEventBus(dispatcher)
+	t.Cleanup(bus.Close)
+
+	events, _ := player.Events()
+	return bus, dispatcher, player, bus.Source(events)
+}
+
+func TestEventBusDeliversThroughDispatcher(t *testing.T) {
+	bus, dispatcher, player, events := newBusPlayer(t)
+
+	var got []interface{}
+	for _, event := range []vlc.Event{vlc.MediaPlayerTimeChanged, vlc.MediaPlayerEndReached} {
+		if _, err := events.Attach(event, func(event vlc.Event, payload interface{}, userData interface{}) {
+			got = append(got, payload, userData)
+		}, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	player.Play()
+	player.Advance(2 * time.Second)
+	if len(got) != 0 {
+		t.Fatalf("Expected nothing to be delivered before the dispatcher runs, got %v", got)
+	}
+
+	bus.Flush()
+	select {
+	case <-dispatcher.Ready():
+	default:
+		t.Fatalf("Expected the dispatcher to be ready")
+	}
+	dispatcher.RunPending()
+
+	expected := []interface{}{
+		vlc.TimeChangedPayload{Time: time.Second}, vlc.MediaPlayerTimeChanged,
+		nil, vlc.MediaPlayerEndReached,
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, got)
+			break
+		}
+	}
+}
+
+func TestEventBusCallbacksCanUsePlayer(t *testing.T) {
+	bus, dispatcher, player, events := newBusPlayer(t)
+
+	// libvlc holds its locks while delivering events, so stopping the player
+	// there would deadlock; through the bus, it happens later.
+	var stopped bool
+	events.Attach(vlc.MediaPlayerPlaying, func(vlc.Event, interface{}, interface{}) {
+		player.Stop()
+		stopped = true
+	}, nil)
+
+	player.Play()
+	if stopped || player.State() != vlcfake.Playing {
+		t.Fatalf("Expected the callback to wait for the dispatcher")
+	}
+
+	bus.Flush()
+	dispatcher.RunPending()
+	if !stopped || player.State() != vlcfake.Stopped {
+		t.Errorf("Expected the callback to have stopped the player")
+	}
+}
+
+func TestEventBusDropsDetachedEvents(t *testing.T) {
+	bus, dispatcher, player, events := newBusPlayer(t)
+
+	var delivered int
+	id, _ := events.Attach(vlc.MediaPlayerPlaying, func(vlc.Event, interface{}, interface{}) {
+		delivered++
+	}, nil)
+
+	player.Play()
+	bus.Flush()
+	events.Detach(id)
+	dispatcher.RunPending()
+
+	if delivered != 0 {
+		t.Errorf("Expected an event queued before detaching not to be delivered")
+	}
+	if player.Attached() != 0 {
+		t.Errorf("Expected the callback to be detached from the player")
+	}
+}
+
+func TestEventBusClose(t *testing.T) {
+	bus, dispatcher, player, events := newBusPlayer(t)
+
+	var delivered int
+	events.Attach(vlc.MediaPlayerPlaying, func(vlc.Event, interface{}, interface{}) {
+		delivered++
+	}, nil)
+
+	bus.Close()
+	player.Play()
+	bus.Flush()
+	dispatcher.RunPending()
+
+	if delivered != 0 {
+		t.Errorf("Expected no events to be delivered once closed")
+	}
+}
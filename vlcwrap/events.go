@@ -0,0 +1,222 @@
+package vlcwrap
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+	"unsafe"
+)
+
+// Media events.
+const (
+	MediaMetaChanged Event = iota
+	MediaSubItemAdded
+	MediaDurationChanged
+	MediaParsedChanged
+	MediaFreed
+	MediaStateChanged
+	MediaSubItemTreeAdded
+)
+
+// TrackType is the kind of an elementary stream, or track.
+type TrackType int
+
+const (
+	TrackUnknown TrackType = iota - 1
+	TrackAudio
+	TrackVideo
+	TrackText
+)
+
+// MediaState is what a media's player is doing with it.
+type MediaState int
+
+const (
+	MediaNothingSpecial MediaState = iota
+	MediaOpening
+	MediaBuffering
+	MediaPlaying
+	MediaPaused
+	MediaStopped
+	MediaEnded
+	MediaError
+)
+
+// ParsedStatus is how parsing a media went.
+type ParsedStatus int
+
+const (
+	ParsedSkipped ParsedStatus = iota + 1
+	ParsedFailed
+	ParsedTimeout
+	ParsedDone
+)
+
+// The payloads passed to event callbacks, for the events that carry one.
+// Events without one are passed a nil payload.
+type (
+	// TimeChangedPayload is sent with MediaPlayerTimeChanged.
+	TimeChangedPayload struct {
+		Time time.Duration
+	}
+	// PositionChangedPayload is sent with MediaPlayerPositionChanged, the
+	// position being a proportion of the length between 0 and 1.
+	PositionChangedPayload struct {
+		Position float64
+	}
+	// LengthChangedPayload is sent with MediaPlayerLengthChanged.
+	LengthChangedPayload struct {
+		Length time.Duration
+	}
+	// BufferingPayload is sent with MediaPlayerBuffering, as a percentage.
+	BufferingPayload struct {
+		Percent float64
+	}
+	// SeekableChangedPayload is sent with MediaPlayerSeekableChanged.
+	SeekableChangedPayload struct {
+		Seekable bool
+	}
+	// PausableChangedPayload is sent with MediaPlayerPausableChanged.
+	PausableChangedPayload struct {
+		Pausable bool
+	}
+	// ScrambledChangedPayload is sent with MediaPlayerScrambledChanged.
+	ScrambledChangedPayload struct {
+		Scrambled bool
+	}
+	// TitleChangedPayload is sent with MediaPlayerTitleChanged.
+	TitleChangedPayload struct {
+		Title int
+	}
+	// ChapterChangedPayload is sent with MediaPlayerChapterChanged.
+	ChapterChangedPayload struct {
+		Chapter int
+	}
+	// VoutPayload is sent with MediaPlayerVout, giving how many video
+	// outputs there now are.
+	VoutPayload struct {
+		Count int
+	}
+	// SnapshotTakenPayload is sent with MediaPlayerSnapshotTaken.
+	SnapshotTakenPayload struct {
+		Filename string
+	}
+	// ESPayload is sent with MediaPlayerESAdded, MediaPlayerESDeleted and
+	// MediaPlayerESSelected. ID is -1 when a track of the type is
+	// deselected.
+	ESPayload struct {
+		Type TrackType
+		ID   int
+	}
+	// AudioVolumePayload is sent with MediaPlayerAudioVolume, 1 being
+	// normal volume.
+	AudioVolumePayload struct {
+		Volume float64
+	}
+	// AudioDevicePayload is sent with MediaPlayerAudioDevice.
+	AudioDevicePayload struct {
+		Device string
+	}
+	// EncounteredErrorPayload is sent with MediaPlayerEncounteredError.
+	// libvlc doesn't say what went wrong in the event itself, so Message
+	// is whatever error libvlc last recorded, and is often empty.
+	EncounteredErrorPayload struct {
+		Message string
+	}
+	// MetaChangedPayload is sent with MediaMetaChanged.
+	MetaChangedPayload struct {
+		Meta MetaType
+	}
+	// DurationChangedPayload is sent with MediaDurationChanged.
+	DurationChangedPayload struct {
+		Duration time.Duration
+	}
+	// ParsedChangedPayload is sent with MediaParsedChanged.
+	ParsedChangedPayload struct {
+		Status ParsedStatus
+	}
+	// StateChangedPayload is sent with MediaStateChanged.
+	StateChangedPayload struct {
+		State MediaState
+	}
+)
+
+// decodeEventPayload decodes the payload of event from the bytes of
+// libvlc_event_t's union, as laid out by the C compiler: every member starts
+// at the beginning of the union. Strings are passed as pointers, which
+// readString follows.
+func decodeEventPayload(event Event, union []byte, readString func(unsafe.Pointer) string) interface{} {
+	order := binary.LittleEndian
+	i32 := func(offset int) int {
+		if len(union) < offset+4 {
+			return 0
+		}
+		return int(int32(order.Uint32(union[offset:])))
+	}
+	i64 := func() int64 {
+		if len(union) < 8 {
+			return 0
+		}
+		return int64(order.Uint64(union))
+	}
+	f32 := func() float64 {
+		if len(union) < 4 {
+			return 0
+		}
+		return float64(math.Float32frombits(order.Uint32(union)))
+	}
+	str := func() string {
+		if len(union) < int(unsafe.Sizeof(unsafe.Pointer(nil))) {
+			return ""
+		}
+		ptr := *(*unsafe.Pointer)(unsafe.Pointer(&union[0]))
+		if ptr == nil {
+			return ""
+		}
+		return readString(ptr)
+	}
+	ms := func(ms int64) time.Duration {
+		return time.Duration(ms) * time.Millisecond
+	}
+
+	switch event {
+	case MediaPlayerTimeChanged:
+		return TimeChangedPayload{Time: ms(i64())}
+	case MediaPlayerPositionChanged:
+		return PositionChangedPayload{Position: f32()}
+	case MediaPlayerLengthChanged:
+		return LengthChangedPayload{Length: ms(i64())}
+	case MediaPlayerBuffering:
+		return BufferingPayload{Percent: f32()}
+	case MediaPlayerSeekableChanged:
+		return SeekableChangedPayload{Seekable: i32(0) != 0}
+	case MediaPlayerPausableChanged:
+		return PausableChangedPayload{Pausable: i32(0) != 0}
+	case MediaPlayerScrambledChanged:
+		return ScrambledChangedPayload{Scrambled: i32(0) != 0}
+	case MediaPlayerTitleChanged:
+		return TitleChangedPayload{Title: i32(0)}
+	case MediaPlayerChapterChanged:
+		return ChapterChangedPayload{Chapter: i32(0)}
+	case MediaPlayerVout:
+		return VoutPayload{Count: i32(0)}
+	case MediaPlayerSnapshotTaken:
+		return SnapshotTakenPayload{Filename: str()}
+	case MediaPlayerESAdded, MediaPlayerESDeleted, MediaPlayerESSelected:
+		return ESPayload{Type: TrackType(i32(0)), ID: i32(4)}
+	case MediaPlayerAudioVolume:
+		return AudioVolumePayload{Volume: f32()}
+	case MediaPlayerAudioDevice:
+		return AudioDevicePayload{Device: str()}
+	case MediaMetaChanged:
+		return MetaChangedPayload{Meta: MetaType(i32(0))}
+	case MediaDurationChanged:
+		return DurationChangedPayload{Duration: ms(i64())}
+	case MediaParsedChanged:
+		return ParsedChangedPayload{Status: ParsedStatus(i32(0))}
+	case MediaStateChanged:
+		return StateChangedPayload{State: MediaState(i32(0))}
+	}
+
+	return nil
+}
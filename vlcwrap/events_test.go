@@ -0,0 +1,81 @@
+package vlcwrap
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// union builds the bytes of an event's union, as libvlc would fill them.
+func union(values ...interface{}) []byte {
+	u := make([]byte, 16)
+	offset := 0
+	for _, value := range values {
+		switch v := value.(type) {
+		case int32:
+			binary.LittleEndian.PutUint32(u[offset:], uint32(v))
+			offset += 4
+		case int64:
+			binary.LittleEndian.PutUint64(u[offset:], uint64(v))
+			offset += 8
+		case float32:
+			binary.LittleEndian.PutUint32(u[offset:], math.Float32bits(v))
+			offset += 4
+		case unsafe.Pointer:
+			if unsafe.Sizeof(v) == 8 {
+				binary.LittleEndian.PutUint64(u[offset:], uint64(uintptr(v)))
+			} else {
+				binary.LittleEndian.PutUint32(u[offset:], uint32(uintptr(v)))
+			}
+			offset += int(unsafe.Sizeof(v))
+		}
+	}
+	return u
+}
+
+func TestDecodeEventPayload(t *testing.T) {
+	snapshot := unsafe.Pointer(new(byte))
+	strings := map[unsafe.Pointer]string{snapshot: `C:\snapshots\one.png`}
+	readString := func(ptr unsafe.Pointer) string {
+		return strings[ptr]
+	}
+
+	for _, test := range []struct {
+		event    Event
+		union    []byte
+		expected interface{}
+	}{
+		{MediaPlayerTimeChanged, union(int64(90500)), TimeChangedPayload{Time: 90500 * time.Millisecond}},
+		{MediaPlayerLengthChanged, union(int64(-1)), LengthChangedPayload{Length: -time.Millisecond}},
+		{MediaPlayerPositionChanged, union(float32(0.5)), PositionChangedPayload{Position: 0.5}},
+		{MediaPlayerBuffering, union(float32(37.5)), BufferingPayload{Percent: 37.5}},
+		{MediaPlayerSeekableChanged, union(int32(1)), SeekableChangedPayload{Seekable: true}},
+		{MediaPlayerPausableChanged, union(int32(0)), PausableChangedPayload{Pausable: false}},
+		{MediaPlayerVout, union(int32(2)), VoutPayload{Count: 2}},
+		{MediaPlayerChapterChanged, union(int32(3)), ChapterChangedPayload{Chapter: 3}},
+		{MediaPlayerESAdded, union(int32(1), int32(7)), ESPayload{Type: TrackVideo, ID: 7}},
+		{MediaPlayerESSelected, union(int32(0), int32(-1)), ESPayload{Type: TrackAudio, ID: -1}},
+		{MediaPlayerAudioVolume, union(float32(1)), AudioVolumePayload{Volume: 1}},
+		{MediaPlayerSnapshotTaken, union(snapshot), SnapshotTakenPayload{Filename: `C:\snapshots\one.png`}},
+		{MediaPlayerAudioDevice, union(unsafe.Pointer(nil)), AudioDevicePayload{}},
+		{MediaDurationChanged, union(int64(2000)), DurationChangedPayload{Duration: 2 * time.Second}},
+		{MediaParsedChanged, union(int32(4)), ParsedChangedPayload{Status: ParsedDone}},
+		{MediaStateChanged, union(int32(6)), StateChangedPayload{State: MediaEnded}},
+		{MediaMetaChanged, union(int32(5)), MetaChangedPayload{Meta: 5}},
+		{MediaPlayerEndReached, union(), nil},
+		{MediaPlayerPlaying, union(), nil},
+	} {
+		if got := decodeEventPayload(test.event, test.union, readString); got != test.expected {
+			t.Errorf("Event %d decoded as %#v, expected %#v", test.event, got, test.expected)
+		}
+	}
+}
+
+func TestDecodeEventPayloadShortUnion(t *testing.T) {
+	got := decodeEventPayload(MediaPlayerTimeChanged, []byte{1, 2}, nil)
+	if got != (TimeChangedPayload{}) {
+		t.Errorf("Expected a zero payload from a short union, got %#v", got)
+	}
+}
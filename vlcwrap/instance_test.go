@@ -0,0 +1,76 @@
+package vlcwrap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInstanceInUse(t *testing.T) {
+	i := &Instance{}
+	if err := i.inUse(); err != nil {
+		t.Fatalf("Expected a new instance not to be in use, got %v", err)
+	}
+
+	i.track(2, 1)
+	err := i.inUse()
+	var inUse *InstanceInUseError
+	if !errors.As(err, &inUse) || inUse.Players != 2 || inUse.Media != 1 {
+		t.Fatalf("Expected 2 players and 1 media in use, got %v", err)
+	}
+	if !errors.Is(err, ErrInstanceInUse) {
+		t.Errorf("Expected %v to be ErrInstanceInUse", err)
+	}
+
+	i.track(-2, -1)
+	if err := i.inUse(); err != nil {
+		t.Errorf("Expected the instance to be free once everything is released, got %v", err)
+	}
+}
+
+func TestUninitialisedInstance(t *testing.T) {
+	var i *Instance
+	if _, err := i.NewPlayer(); err != ErrModuleNotInitialized {
+		t.Errorf("Expected ErrModuleNotInitialized creating a player, got %v", err)
+	}
+	if _, err := i.NewMediaFromURL("http://example.com/a.mp4"); err != ErrModuleNotInitialized {
+		t.Errorf("Expected ErrModuleNotInitialized creating media, got %v", err)
+	}
+	if err := i.Release(); err != nil {
+		t.Errorf("Expected releasing nothing to succeed, got %v", err)
+	}
+}
+
+func TestEventsAreFoundAcrossInstances(t *testing.T) {
+	a := &Instance{events: newEventRegistry()}
+	b := &Instance{events: newEventRegistry()}
+
+	instances.Lock()
+	instances.live[a] = true
+	instances.live[b] = true
+	instances.Unlock()
+	t.Cleanup(func() {
+		instances.Lock()
+		delete(instances.live, a)
+		delete(instances.live, b)
+		instances.Unlock()
+	})
+
+	callback := func(Event, interface{}, interface{}) {}
+	idA := a.events.add(MediaPlayerEndReached, callback, nil, "a")
+	idB := b.events.add(MediaPlayerEndReached, callback, nil, "b")
+	if idA == idB {
+		t.Fatalf("Expected event ids to be unique across instances")
+	}
+
+	if ctx, ok := findEvent(idB); !ok || ctx.userData != "b" {
+		t.Errorf("Expected to find b's event")
+	}
+
+	// Events of released instances are ignored.
+	instances.Lock()
+	delete(instances.live, a)
+	instances.Unlock()
+	if _, ok := findEvent(idA); ok {
+		t.Errorf("Expected a's event to be ignored once it is released")
+	}
+}
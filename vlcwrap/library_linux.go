@@ -0,0 +1,6 @@
+package vlcwrap
+
+// libraryName is the libvlc shared library to load. This is the soname of
+// libvlc 3.x, which distributions ship without the unversioned link unless
+// the development package is installed.
+const libraryName = "libvlc.so.5"
@@ -0,0 +1,4 @@
+package vlcwrap
+
+// libraryName is the libvlc shared library to load.
+const libraryName = "libvlc.dll"
@@ -0,0 +1,17 @@
+#ifndef VLCWRAP_LOADER_H
+#define VLCWRAP_LOADER_H
+
+#include <stddef.h>
+
+/* Implemented once per platform, in loader_<os>.c. */
+
+/* Opens the shared library at path, or returns NULL with a description of
+ * the failure in err. */
+void *open_vlc_library(const char *path, char *err, size_t errlen);
+
+/* Returns the address of the named function, or NULL if there isn't one. */
+void *find_vlc_symbol(void *lib, const char *name);
+
+void close_vlc_library(void *lib);
+
+#endif
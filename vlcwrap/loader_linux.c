@@ -0,0 +1,25 @@
+#include <dlfcn.h>
+#include <stdio.h>
+
+#include "loader.h"
+
+void *open_vlc_library(const char *path, char *err, size_t errlen)
+{
+    void *lib = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+
+    /* dlerror's message already names the library. */
+    if (!lib)
+        snprintf(err, errlen, "%s", dlerror());
+
+    return lib;
+}
+
+void *find_vlc_symbol(void *lib, const char *name)
+{
+    return dlsym(lib, name);
+}
+
+void close_vlc_library(void *lib)
+{
+    dlclose(lib);
+}
@@ -0,0 +1,48 @@
+#include <stdint.h>
+#include <stdio.h>
+#include <string.h>
+
+#define WIN32_LEAN_AND_MEAN
+#include <windows.h>
+
+#include "loader.h"
+
+void *open_vlc_library(const char *path, char *err, size_t errlen)
+{
+    HMODULE lib;
+
+    /* Given a full path, look for libvlccore.dll alongside libvlc.dll rather
+     * than only on the usual search path. */
+    if (strchr(path, '\\') || strchr(path, '/'))
+        lib = LoadLibraryExA(path, NULL, LOAD_WITH_ALTERED_SEARCH_PATH);
+    else
+        lib = LoadLibraryA(path);
+
+    if (!lib) {
+        DWORD code = GetLastError();
+        char msg[256] = "";
+        size_t len;
+
+        FormatMessageA(FORMAT_MESSAGE_FROM_SYSTEM | FORMAT_MESSAGE_IGNORE_INSERTS,
+                       NULL, code, 0, msg, sizeof(msg), NULL);
+
+        /* FormatMessage ends its messages with a line break. */
+        len = strlen(msg);
+        while (len > 0 && (msg[len - 1] == '\r' || msg[len - 1] == '\n'))
+            msg[--len] = '\0';
+
+        snprintf(err, errlen, "%s: error %lu: %s", path, (unsigned long)code, msg);
+    }
+
+    return lib;
+}
+
+void *find_vlc_symbol(void *lib, const char *name)
+{
+    return (void *)(intptr_t)GetProcAddress((HMODULE)lib, name);
+}
+
+void close_vlc_library(void *lib)
+{
+    FreeLibrary((HMODULE)lib);
+}
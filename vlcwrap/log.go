@@ -0,0 +1,159 @@
+package vlcwrap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// LogLevel is how severe a libvlc log message is.
+type LogLevel int
+
+const (
+	LogDebug   LogLevel = 0
+	LogNotice  LogLevel = 2
+	LogWarning LogLevel = 3
+	LogError   LogLevel = 4
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogNotice:
+		return "notice"
+	case LogWarning:
+		return "warning"
+	case LogError:
+		return "error"
+	}
+	return fmt.Sprintf("level %d", int(l))
+}
+
+// ParseLogLevel reads a level by name, as LogLevel.String gives it.
+func ParseLogLevel(name string) (LogLevel, error) {
+	for _, level := range []LogLevel{LogDebug, LogNotice, LogWarning, LogError} {
+		if strings.EqualFold(name, level.String()) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// LogMessage is a message logged by libvlc.
+type LogMessage struct {
+	Level LogLevel
+	// Module is the libvlc module that logged the message, such as
+	// "avcodec" or "main".
+	Module string
+	// ObjectType says what kind of object logged the message, such as
+	// "decoder" or "vout display", and ObjectID which one it was.
+	ObjectType   string
+	ObjectHeader string
+	ObjectID     uintptr
+	Text         string
+}
+
+// String formats the message much as libvlc's own console log does.
+func (lm LogMessage) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%016x] %s %s", lm.ObjectID, lm.Module, lm.ObjectType)
+	if len(lm.ObjectHeader) > 0 {
+		fmt.Fprintf(&b, " (%s)", lm.ObjectHeader)
+	}
+	fmt.Fprintf(&b, " %v: %s", lm.Level, lm.Text)
+	return b.String()
+}
+
+// LogFilter decides which libvlc messages are passed on: those at or above
+// a minimum level, which can be set for each module.
+type LogFilter struct {
+	Level   LogLevel
+	Modules map[string]LogLevel
+}
+
+// Allows reports whether msg should be passed on.
+func (lf LogFilter) Allows(msg LogMessage) bool {
+	if level, ok := lf.Modules[msg.Module]; ok {
+		return msg.Level >= level
+	}
+	return msg.Level >= lf.Level
+}
+
+// ParseLogFilter reads a filter written as a default level followed by any
+// module levels, separated by commas, such as "warning,avcodec=error".
+func ParseLogFilter(spec string) (LogFilter, error) {
+	filter := LogFilter{Level: LogWarning}
+
+	for i, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		module, name := "", part
+		if eq := strings.IndexByte(part, '='); eq >= 0 {
+			module, name = strings.TrimSpace(part[:eq]), strings.TrimSpace(part[eq+1:])
+			if len(module) == 0 {
+				return LogFilter{}, fmt.Errorf("no module given in %q", part)
+			}
+		} else if i > 0 {
+			return LogFilter{}, fmt.Errorf("expected module=level, got %q", part)
+		}
+
+		level, err := ParseLogLevel(name)
+		if err != nil {
+			return LogFilter{}, err
+		}
+
+		if len(module) == 0 {
+			filter.Level = level
+			continue
+		}
+		if filter.Modules == nil {
+			filter.Modules = map[string]LogLevel{}
+		}
+		filter.Modules[module] = level
+	}
+
+	return filter, nil
+}
+
+// logSink is where an instance's log messages go.
+type logSink struct {
+	filter LogFilter
+	logger func(LogMessage)
+}
+
+// logSinks are those set for each instance, by the id libvlc is given for
+// it, as it can't be given a Go pointer.
+var logSinks = struct {
+	sync.RWMutex
+	sinks    map[uintptr]*logSink
+	sequence uintptr
+}{sinks: map[uintptr]*logSink{}}
+
+func addLogSink(sink *logSink) uintptr {
+	logSinks.Lock()
+	defer logSinks.Unlock()
+
+	logSinks.sequence++
+	logSinks.sinks[logSinks.sequence] = sink
+	return logSinks.sequence
+}
+
+func removeLogSink(id uintptr) {
+	logSinks.Lock()
+	defer logSinks.Unlock()
+
+	delete(logSinks.sinks, id)
+}
+
+// dispatchLog passes msg on to the sink with the given id, if it wants it.
+// It is called on libvlc's threads, so loggers must be safe to call from
+// several goroutines at once, and must not call back into libvlc.
+func dispatchLog(id uintptr, msg LogMessage) {
+	logSinks.RLock()
+	sink, ok := logSinks.sinks[id]
+	logSinks.RUnlock()
+
+	if ok && sink.filter.Allows(msg) {
+		sink.logger(msg)
+	}
+}
@@ -0,0 +1,22 @@
+#include <stdio.h>
+#include <stdint.h>
+
+#include <vlc/vlc.h>
+
+#include "_cgo_export.h"
+
+/* Formats a libvlc log message, which Go can't do with a va_list, and hands
+ * it to Go along with where it came from. */
+void vlcwrap_log(void *data, int level, const libvlc_log_t *ctx, const char *fmt, va_list args)
+{
+    char message[1024];
+    const char *module = NULL, *file = NULL, *name = NULL, *header = NULL;
+    unsigned line = 0;
+    uintptr_t object = 0;
+
+    vsnprintf(message, sizeof(message), fmt, args);
+    libvlc_log_get_context(ctx, &module, &file, &line);
+    libvlc_log_get_object(ctx, &name, &header, &object);
+
+    logDispatch((uintptr_t)data, level, (char *)module, (char *)name, (char *)header, object, message);
+}
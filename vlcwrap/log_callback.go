@@ -0,0 +1,61 @@
+package vlcwrap
+
+/*
+#include <stdint.h>
+
+#include <vlc/vlc.h>
+
+extern void vlcwrap_log(void*, int, const libvlc_log_t*, const char*, va_list);
+
+static inline void logSet(libvlc_instance_t* inst, uintptr_t id) {
+    libvlc_log_set(inst, vlcwrap_log, (void*)id);
+}
+*/
+import "C"
+
+// SetLogger sends the default instance's log messages to logger, as
+// Instance.SetLogger does.
+func SetLogger(filter LogFilter, logger func(LogMessage)) error {
+	return defaultInstance.SetLogger(filter, logger)
+}
+
+// SetLogger sends the instance's log messages that filter allows to logger,
+// instead of libvlc's own console log. A nil logger discards them. logger is
+// called on libvlc's threads, perhaps several at once, and must not call
+// back into libvlc.
+func (i *Instance) SetLogger(filter LogFilter, logger func(LogMessage)) error {
+	if err := i.assertInit(); err != nil {
+		return err
+	}
+
+	i.Lock()
+	previous := i.logSink
+	i.logSink = 0
+	if logger == nil {
+		C.libvlc_log_unset(i.handle)
+	} else {
+		i.logSink = addLogSink(&logSink{filter: filter, logger: logger})
+		C.logSet(i.handle, C.uintptr_t(i.logSink))
+	}
+	i.Unlock()
+
+	// libvlc has finished with the previous sink once the callback is
+	// replaced.
+	if previous != 0 {
+		removeLogSink(previous)
+	}
+
+	return getError()
+}
+
+//export logDispatch
+func logDispatch(id C.uintptr_t, level C.int, module, objectType, objectHeader *C.char, objectID C.uintptr_t, text *C.char) {
+	dispatchLog(uintptr(id), LogMessage{
+		Level:        LogLevel(level),
+		Module:       C.GoString(module),
+		ObjectType:   C.GoString(objectType),
+		ObjectHeader: C.GoString(objectHeader),
+		ObjectID:     uintptr(objectID),
+		Text:         C.GoString(text),
+	})
+}
@@ -0,0 +1,65 @@
+package vlcwrap
+
+import "testing"
+
+func TestParseLogFilter(t *testing.T) {
+	filter, err := ParseLogFilter("notice, avcodec=error,main=debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Level != LogNotice || len(filter.Modules) != 2 || filter.Modules["avcodec"] != LogError || filter.Modules["main"] != LogDebug {
+		t.Errorf("Unexpected filter %+v", filter)
+	}
+
+	if filter, err := ParseLogFilter("ERROR"); err != nil || filter.Level != LogError || filter.Modules != nil {
+		t.Errorf("Expected a plain error filter, got %+v, %v", filter, err)
+	}
+
+	for _, spec := range []string{"", "loud", "warning,avcodec", "warning,=debug", "warning,avcodec=loud"} {
+		if _, err := ParseLogFilter(spec); err == nil {
+			t.Errorf("Expected %q not to parse", spec)
+		}
+	}
+}
+
+func TestLogFilterAllows(t *testing.T) {
+	filter := LogFilter{Level: LogWarning, Modules: map[string]LogLevel{"avcodec": LogError, "main": LogDebug}}
+
+	for _, test := range []struct {
+		module  string
+		level   LogLevel
+		allowed bool
+	}{
+		{"direct3d11", LogWarning, true},
+		{"direct3d11", LogNotice, false},
+		{"avcodec", LogWarning, false},
+		{"avcodec", LogError, true},
+		{"main", LogDebug, true},
+	} {
+		if got := filter.Allows(LogMessage{Module: test.module, Level: test.level}); got != test.allowed {
+			t.Errorf("Allows(%v %v) = %v, expected %v", test.module, test.level, got, test.allowed)
+		}
+	}
+}
+
+func TestDispatchLog(t *testing.T) {
+	var got []LogMessage
+	id := addLogSink(&logSink{
+		filter: LogFilter{Level: LogWarning},
+		logger: func(msg LogMessage) {
+			got = append(got, msg)
+		},
+	})
+
+	dispatchLog(id, LogMessage{Level: LogDebug, Module: "main", Text: "dropped"})
+	dispatchLog(id, LogMessage{Level: LogError, Module: "main", ObjectType: "input", ObjectID: 0xbeef, Text: "kept"})
+	removeLogSink(id)
+	dispatchLog(id, LogMessage{Level: LogError, Module: "main", Text: "after removal"})
+
+	if len(got) != 1 || got[0].Text != "kept" {
+		t.Fatalf("Expected only the error to be logged, got %v", got)
+	}
+	if s := got[0].String(); s != "[000000000000beef] main input error: kept" {
+		t.Errorf("Unexpected formatting %q", s)
+	}
+}
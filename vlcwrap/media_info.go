@@ -0,0 +1,124 @@
+package vlcwrap
+
+import "strings"
+
+// MetaType identifies a piece of a media's metadata.
+type MetaType int
+
+const (
+	MetaTitle MetaType = iota
+	MetaArtist
+	MetaGenre
+	MetaCopyright
+	MetaAlbum
+	MetaTrackNumber
+	MetaDescription
+	MetaRating
+	MetaDate
+	MetaSetting
+	MetaURL
+	MetaLanguage
+	MetaNowPlaying
+	MetaPublisher
+	MetaEncodedBy
+	MetaArtworkURL
+	MetaTrackID
+	MetaTrackTotal
+	MetaDirector
+	MetaSeason
+	MetaEpisode
+	MetaShowName
+	MetaActors
+	MetaAlbumArtist
+	MetaDiscNumber
+	MetaDiscTotal
+)
+
+// VideoOrientation is how a video track's pictures must be turned to be
+// shown the right way up.
+type VideoOrientation int
+
+const (
+	// OrientNormal pictures need no turning.
+	OrientNormal VideoOrientation = iota
+	OrientFlippedHorizontally
+	OrientFlippedVertically
+	OrientRotated180
+	OrientTransposed
+	// OrientRotated90 pictures are turned 90 degrees clockwise.
+	OrientRotated90
+	// OrientRotated270 pictures are turned 90 degrees anticlockwise.
+	OrientRotated270
+	OrientAntiTransposed
+)
+
+// Transposed reports whether the pictures' width and height are swapped
+// when they are turned.
+func (vo VideoOrientation) Transposed() bool {
+	return vo >= OrientTransposed
+}
+
+// Track is one of a media's elementary streams.
+type Track struct {
+	Type TrackType
+	ID   int
+	// Codec is the codec's four character code, e.g. "h264", and
+	// CodecDescription libvlc's name for it.
+	Codec            string
+	CodecDescription string
+	Bitrate          uint
+	Language         string
+	Description      string
+	// Video is set for video tracks.
+	Video *VideoTrack
+}
+
+// VideoTrack describes the pictures of a video track.
+type VideoTrack struct {
+	Width, Height uint
+	// SARNum and SARDen give the shape of the pixels, as a ratio of width
+	// to height. Either may be 0 if it isn't known.
+	SARNum, SARDen uint
+	FrameRateNum   uint
+	FrameRateDen   uint
+	Orientation    VideoOrientation
+}
+
+// FrameRate returns the track's frames per second, or 0 if it isn't known.
+func (vt VideoTrack) FrameRate() float64 {
+	if vt.FrameRateDen == 0 {
+		return 0
+	}
+	return float64(vt.FrameRateNum) / float64(vt.FrameRateDen)
+}
+
+// DisplaySize returns the size the track's pictures are shown at, allowing
+// for non-square pixels and rotation.
+func (vt VideoTrack) DisplaySize() (uint, uint) {
+	width, height := vt.Width, vt.Height
+	if vt.SARNum != 0 && vt.SARDen != 0 {
+		width = width * vt.SARNum / vt.SARDen
+	}
+	if vt.Orientation.Transposed() {
+		width, height = height, width
+	}
+	return width, height
+}
+
+// fourcc spells out a four character code, as libvlc stores them, without
+// any trailing spaces.
+func fourcc(code uint32) string {
+	b := []byte{byte(code), byte(code >> 8), byte(code >> 16), byte(code >> 24)}
+	return strings.TrimRight(string(b), " \x00")
+}
+
+// parsedStatusError says what went wrong with a parse, if anything.
+func parsedStatusError(status ParsedStatus) error {
+	switch status {
+	case ParsedDone:
+		return nil
+	case ParsedTimeout:
+		return ErrMediaParseTimeout
+	}
+	return ErrMediaParse
+}
@@ -0,0 +1,56 @@
+package vlcwrap
+
+import "testing"
+
+func TestVideoTrackDisplaySize(t *testing.T) {
+	for _, test := range []struct {
+		track         VideoTrack
+		width, height uint
+	}{
+		{VideoTrack{Width: 1920, Height: 1080}, 1920, 1080},
+		// Anamorphic DVD: 720x576 with 16:15 pixels.
+		{VideoTrack{Width: 720, Height: 576, SARNum: 16, SARDen: 15}, 768, 576},
+		{VideoTrack{Width: 1920, Height: 1080, SARNum: 1}, 1920, 1080},
+		{VideoTrack{Width: 1920, Height: 1080, Orientation: OrientRotated90}, 1080, 1920},
+		{VideoTrack{Width: 1920, Height: 1080, Orientation: OrientRotated180}, 1920, 1080},
+	} {
+		if width, height := test.track.DisplaySize(); width != test.width || height != test.height {
+			t.Errorf("%+v shown at %dx%d, expected %dx%d", test.track, width, height, test.width, test.height)
+		}
+	}
+}
+
+func TestVideoTrackFrameRate(t *testing.T) {
+	if got := (VideoTrack{FrameRateNum: 30000, FrameRateDen: 1001}).FrameRate(); got < 29.97 || got > 29.98 {
+		t.Errorf("Expected NTSC's 29.97 frames per second, got %v", got)
+	}
+	if got := (VideoTrack{FrameRateNum: 25}).FrameRate(); got != 0 {
+		t.Errorf("Expected an unknown frame rate to be 0, got %v", got)
+	}
+}
+
+func TestFourcc(t *testing.T) {
+	for code, expected := range map[uint32]string{
+		0x34363268: "h264",
+		0x30323449: "I420",
+		0x20337061: "ap3",
+		0:          "",
+	} {
+		if got := fourcc(code); got != expected {
+			t.Errorf("fourcc(%#x) = %q, expected %q", code, got, expected)
+		}
+	}
+}
+
+func TestParsedStatusError(t *testing.T) {
+	for status, expected := range map[ParsedStatus]error{
+		ParsedDone:    nil,
+		ParsedTimeout: ErrMediaParseTimeout,
+		ParsedFailed:  ErrMediaParse,
+		ParsedSkipped: ErrMediaParse,
+	} {
+		if got := parsedStatusError(status); got != expected {
+			t.Errorf("parsedStatusError(%d) = %v, expected %v", status, got, expected)
+		}
+	}
+}
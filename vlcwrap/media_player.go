@@ -0,0 +1,68 @@
+package vlcwrap
+
+import "time"
+
+// MediaPlayer is what a program needs of a Player to play media through it.
+// Code written against it can be tested with a fake, such as the one in the
+// vlcfake package, on a machine without libvlc. Setting a player up to
+// render into a window is left to Player itself.
+type MediaPlayer interface {
+	// Load loads the media at location, a path if local is set and a URL
+	// otherwise, and sets it as the player's current media.
+	Load(location string, local bool) (MediaHandle, error)
+	Play() error
+	SetPause(pause bool) error
+	Stop() error
+	IsPlaying() bool
+	IsSeekable() bool
+	Length() (time.Duration, error)
+	Time() (time.Duration, error)
+	SetTime(t time.Duration) error
+	Rate() (float64, error)
+	SetRate(rate float64) error
+	SetAspectRatio(aspect string) error
+	SetCropGeometry(geometry string) error
+	// Events returns the source of the player's events.
+	Events() (EventSource, error)
+	Release() error
+}
+
+// MediaHandle is media loaded into a MediaPlayer. It must be released once
+// the player has moved on from it.
+type MediaHandle interface {
+	AddOption(option string) error
+	Release() error
+}
+
+// EventSource delivers events to callbacks. Callbacks may be called on any
+// thread, and must not call back into the player.
+type EventSource interface {
+	Attach(event Event, callback EventCallback, userData interface{}) (EventID, error)
+	Detach(eventIDs ...EventID)
+}
+
+var (
+	_ MediaPlayer = (*Player)(nil)
+	_ MediaHandle = (*Media)(nil)
+	_ EventSource = (*EventManager)(nil)
+)
+
+// Load implements MediaPlayer, loading media as LoadMediaFromPath or
+// LoadMediaFromURL do.
+func (p *Player) Load(location string, local bool) (MediaHandle, error) {
+	media, err := p.loadMedia(location, local)
+	if err != nil {
+		// Not a nil *Media in a non-nil interface.
+		return nil, err
+	}
+	return media, nil
+}
+
+// Events implements MediaPlayer, returning the player's event manager.
+func (p *Player) Events() (EventSource, error) {
+	manager, err := p.EventManager()
+	if err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
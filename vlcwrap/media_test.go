@@ -0,0 +1,137 @@
+package vlcwrap
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeY4M writes an uncompressed clip of grey frames, which libvlc can
+// read without any codecs.
+func writeY4M(t *testing.T, path string, width, height, frames, fps int) {
+	t.Helper()
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C420jpeg\n", width, height, fps)
+	frame := bytes.Repeat([]byte{0x80}, width*height*3/2)
+	for i := 0; i < frames; i++ {
+		b.WriteString("FRAME\n")
+		b.Write(frame)
+	}
+
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestInstance creates an instance for a test, skipping it if libvlc
+// isn't installed.
+func newTestInstance(t *testing.T) *Instance {
+	t.Helper()
+
+	i, err := NewInstance("--no-audio", "--quiet")
+	if err != nil {
+		t.Skipf("libvlc unavailable: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := i.Release(); err != nil {
+			t.Errorf("Unable to release the instance: %v", err)
+		}
+	})
+	return i
+}
+
+func TestMediaParse(t *testing.T) {
+	inst := newTestInstance(t)
+
+	path := filepath.Join(t.TempDir(), "clip.y4m")
+	writeY4M(t, path, 64, 48, 10, 25)
+
+	media, err := inst.NewMediaFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer media.Release()
+
+	events, err := media.EventManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := make(chan ParsedStatus, 1)
+	id, err := events.Attach(MediaParsedChanged, func(event Event, payload interface{}, userData interface{}) {
+		select {
+		case parsed <- payload.(ParsedChangedPayload).Status:
+		default:
+		}
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Detach(id)
+
+	if err := media.ParseAsync(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case status := <-parsed:
+		if status != ParsedDone {
+			t.Fatalf("Expected parsing to succeed, got status %d", status)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Timed out waiting for the parsed event")
+	}
+	if status := media.ParsedStatus(); status != ParsedDone {
+		t.Errorf("Expected the parsed status to be done, got %d", status)
+	}
+
+	tracks, err := media.Tracks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 1 || tracks[0].Type != TrackVideo || tracks[0].Video == nil {
+		t.Fatalf("Expected a single video track, got %+v", tracks)
+	}
+	video := tracks[0].Video
+	if video.Width != 64 || video.Height != 48 || video.FrameRate() != 25 || video.Orientation != OrientNormal {
+		t.Errorf("Unexpected video track %+v", *video)
+	}
+	if len(tracks[0].Codec) == 0 {
+		t.Errorf("Expected the track to have a codec")
+	}
+
+	// Ten frames at 25 per second.
+	if duration, err := media.Duration(); err != nil || duration < 360*time.Millisecond || duration > 440*time.Millisecond {
+		t.Errorf("Expected a duration of 400ms, got %v, %v", duration, err)
+	}
+
+	// Without a title of its own, media is named after its file.
+	if title, err := media.Meta(MetaTitle); err != nil || title != "clip.y4m" {
+		t.Errorf("Expected the title to be the file name, got %q, %v", title, err)
+	}
+	if artist, err := media.Meta(MetaArtist); err != nil || artist != "" {
+		t.Errorf("Expected no artist, got %q, %v", artist, err)
+	}
+}
+
+func TestMediaParseWaits(t *testing.T) {
+	inst := newTestInstance(t)
+
+	path := filepath.Join(t.TempDir(), "clip.y4m")
+	writeY4M(t, path, 32, 32, 5, 10)
+
+	media, err := inst.NewMediaFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer media.Release()
+
+	if err := media.Parse(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if width, height, err := media.VideoSize(); err != nil || width != 32 || height != 32 {
+		t.Errorf("Expected 32x32 video, got %dx%d, %v", width, height, err)
+	}
+}
@@ -0,0 +1,29 @@
+package vlcwrap
+
+import "testing"
+
+func TestUninitialisedPlayer(t *testing.T) {
+	for _, p := range []*Player{nil, {}} {
+		if _, err := p.Length(); err != ErrPlayerNotInitialized {
+			t.Errorf("Expected ErrPlayerNotInitialized from Length, got %v", err)
+		}
+		if _, err := p.Time(); err != ErrPlayerNotInitialized {
+			t.Errorf("Expected ErrPlayerNotInitialized from Time, got %v", err)
+		}
+		if err := p.SetTime(0); err != ErrPlayerNotInitialized {
+			t.Errorf("Expected ErrPlayerNotInitialized from SetTime, got %v", err)
+		}
+		if _, err := p.Position(); err != ErrPlayerNotInitialized {
+			t.Errorf("Expected ErrPlayerNotInitialized from Position, got %v", err)
+		}
+		if err := p.SetPosition(0.5); err != ErrPlayerNotInitialized {
+			t.Errorf("Expected ErrPlayerNotInitialized from SetPosition, got %v", err)
+		}
+		if p.IsSeekable() {
+			t.Errorf("Expected an uninitialised player not to be seekable")
+		}
+		if p.WillPlay() {
+			t.Errorf("Expected an uninitialised player not to be able to play")
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package vlcwrap
+
+/*
+#include <vlc/vlc.h>
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VersionInfo is a libvlc version number.
+type VersionInfo struct {
+	Major uint
+	Minor uint
+	Patch uint
+	Extra uint
+}
+
+// MinimumVersion is the oldest libvlc the wrapper supports. Init refuses to
+// use anything older.
+var MinimumVersion = VersionInfo{Major: 3}
+
+var ErrUnsupportedVersion = errors.New("unsupported libvlc version")
+
+// UnsupportedVersionError is returned by Init when the libvlc found is older
+// than MinimumVersion.
+type UnsupportedVersionError struct {
+	Version VersionInfo
+	Runtime string
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("%v: found %q, need at least %v", ErrUnsupportedVersion, e.Runtime, MinimumVersion)
+}
+
+func (e *UnsupportedVersionError) Unwrap() error {
+	return ErrUnsupportedVersion
+}
+
+func (v VersionInfo) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Patch, v.Extra)
+}
+
+// AtLeast reports whether v is the same as or newer than other.
+func (v VersionInfo) AtLeast(other VersionInfo) bool {
+	a := [...]uint{v.Major, v.Minor, v.Patch, v.Extra}
+	b := [...]uint{other.Major, other.Minor, other.Patch, other.Extra}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return true
+}
+
+// parseVersion reads the version number from the start of a libvlc version
+// string such as "3.0.16 Vetinari" or "4.0.0-dev Otto Chriek". Anything it
+// can't make sense of is taken as 0.
+func parseVersion(runtime string) VersionInfo {
+	var numbers [4]uint
+
+	fields := strings.Fields(runtime)
+	if len(fields) == 0 {
+		return VersionInfo{}
+	}
+
+	for i, part := range strings.SplitN(fields[0], ".", len(numbers)) {
+		end := strings.IndexFunc(part, func(r rune) bool { return r < '0' || r > '9' })
+		if end >= 0 {
+			part = part[:end]
+		}
+
+		value, _ := strconv.ParseUint(part, 10, 32)
+		numbers[i] = uint(value)
+	}
+
+	return VersionInfo{Major: numbers[0], Minor: numbers[1], Patch: numbers[2], Extra: numbers[3]}
+}
+
+// RuntimeVersion returns the version string of the libvlc that was loaded,
+// e.g. "3.0.16 Vetinari", or "" if none has been.
+func RuntimeVersion() string {
+	if !libraryLoaded {
+		return ""
+	}
+	return C.GoString(C.libvlc_get_version())
+}
+
+// Version returns the version number of the libvlc that was loaded.
+func Version() VersionInfo {
+	return parseVersion(RuntimeVersion())
+}
+
+// Compiler returns the compiler the loaded libvlc was built with, or "" if
+// none has been loaded.
+func Compiler() string {
+	if !libraryLoaded {
+		return ""
+	}
+	return C.GoString(C.libvlc_get_compiler())
+}
@@ -0,0 +1,30 @@
+package vlcwrap
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	for runtime, expected := range map[string]VersionInfo{
+		"3.0.16 Vetinari":       {Major: 3, Minor: 0, Patch: 16},
+		"4.0.0-dev Otto Chriek": {Major: 4},
+		"2.2.8 Weatherwax":      {Major: 2, Minor: 2, Patch: 8},
+		"3.0.11.1 Vetinari":     {Major: 3, Patch: 11, Extra: 1},
+		"":                      {},
+		"not a version":         {},
+	} {
+		if got := parseVersion(runtime); got != expected {
+			t.Errorf("parseVersion(%q) = %v, expected %v", runtime, got, expected)
+		}
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	if !(VersionInfo{Major: 3, Patch: 16}).AtLeast(MinimumVersion) {
+		t.Errorf("3.0.16 should be supported")
+	}
+	if (VersionInfo{Major: 2, Minor: 2, Patch: 8}).AtLeast(MinimumVersion) {
+		t.Errorf("2.2.8 should not be supported")
+	}
+	if !(VersionInfo{Major: 3}).AtLeast(VersionInfo{Major: 3}) {
+		t.Errorf("A version should be at least itself")
+	}
+}
@@ -1,5 +1,9 @@
+#include <stdio.h>
+
 #include <vlc/vlc.h>
 
+#include "loader.h"
+
 #define STUB___0(func)                  \
     typedef void (*TYPE_##func)(void);  \
     void (*PTR_##func)(void);           \
@@ -18,6 +22,12 @@
     void func(arg1 _a1, arg2 _a2)       \
     { PTR_##func(_a1, _a2); }
 
+#define STUB___3(func, arg1, arg2, arg3)        \
+    typedef void (*TYPE_##func)(arg1, arg2, arg3); \
+    void (*PTR_##func)(arg1, arg2, arg3);       \
+    void func(arg1 _a1, arg2 _a2, arg3 _a3)     \
+    { PTR_##func(_a1, _a2, _a3); }
+
 #define STUB___4(func, arg1, arg2, arg3, arg4)              \
     typedef void (*TYPE_##func)(arg1, arg2, arg3, arg4);    \
     void (*PTR_##func)(arg1, arg2, arg3, arg4);             \
@@ -42,6 +52,12 @@
     ret func(arg1 _a1, arg2 _a2)        \
     { return PTR_##func(_a1, _a2); }
 
+#define STUB_R_3(ret, func, arg1, arg2, arg3)           \
+    typedef ret (*TYPE_##func)(arg1, arg2, arg3);       \
+    ret (*PTR_##func)(arg1, arg2, arg3);                \
+    ret func(arg1 _a1, arg2 _a2, arg3 _a3)              \
+    { return PTR_##func(_a1, _a2, _a3); }
+
 #define STUB_R_4(ret, func, arg1, arg2, arg3, arg4)     \
     typedef ret (*TYPE_##func)(arg1, arg2, arg3, arg4); \
     ret (*PTR_##func)(arg1, arg2, arg3, arg4);          \
@@ -52,69 +68,170 @@ STUB_R_2(libvlc_instance_t *, libvlc_new, int, const char *const *);
 STUB___1(libvlc_release, libvlc_instance_t *);
 STUB_R_0(const char *, libvlc_errmsg);
 STUB___0(libvlc_clearerr);
+STUB_R_0(const char *, libvlc_get_version);
+STUB_R_0(const char *, libvlc_get_compiler);
 STUB___1(libvlc_media_release, libvlc_media_t *);
 STUB_R_2(libvlc_media_t*, libvlc_media_new_path, libvlc_instance_t *, const char *);
 STUB_R_2(libvlc_media_t*, libvlc_media_new_location, libvlc_instance_t *, const char *);
 STUB_R_1(void*, libvlc_media_get_user_data, libvlc_media_t *);
 STUB___2(libvlc_video_set_key_input, libvlc_media_player_t *, unsigned);
 STUB___2(libvlc_video_set_mouse_input, libvlc_media_player_t *, unsigned);
+STUB___2(libvlc_video_set_aspect_ratio, libvlc_media_player_t *, const char *);
+STUB___2(libvlc_video_set_crop_geometry, libvlc_media_player_t *, const char *);
 STUB_R_4(int, libvlc_event_attach, libvlc_event_manager_t *, libvlc_event_type_t, libvlc_callback_t, void *);
 STUB___4(libvlc_event_detach, libvlc_event_manager_t *, libvlc_event_type_t, libvlc_callback_t, void *);
 STUB___2(libvlc_audio_set_mute, libvlc_media_player_t *, int);
 STUB_R_1(libvlc_event_manager_t *, libvlc_media_player_event_manager, libvlc_media_player_t *);
+STUB_R_1(libvlc_event_manager_t *, libvlc_media_event_manager, libvlc_media_t *);
 STUB_R_1(libvlc_media_t *, libvlc_media_player_get_media, libvlc_media_player_t *);
 STUB_R_1(int, libvlc_media_player_is_playing, libvlc_media_player_t *);
 STUB_R_1(libvlc_media_player_t*, libvlc_media_player_new, libvlc_instance_t *);
 STUB_R_1(int, libvlc_media_player_play, libvlc_media_player_t *);
 STUB___1(libvlc_media_player_release, libvlc_media_player_t *);
 STUB___2(libvlc_media_player_set_hwnd, libvlc_media_player_t *, void *);
+STUB___2(libvlc_media_player_set_xwindow, libvlc_media_player_t *, uint32_t);
 STUB___2(libvlc_media_player_set_media,	libvlc_media_player_t *, libvlc_media_t *);
 STUB___1(libvlc_media_player_stop, libvlc_media_player_t *);
+STUB___2(libvlc_media_player_set_pause, libvlc_media_player_t *, int);
+STUB_R_1(libvlc_time_t, libvlc_media_player_get_length, libvlc_media_player_t *);
+STUB_R_1(libvlc_time_t, libvlc_media_player_get_time, libvlc_media_player_t *);
+STUB___2(libvlc_media_player_set_time, libvlc_media_player_t *, libvlc_time_t);
+STUB_R_1(float, libvlc_media_player_get_position, libvlc_media_player_t *);
+STUB___2(libvlc_media_player_set_position, libvlc_media_player_t *, float);
+STUB_R_1(float, libvlc_media_player_get_rate, libvlc_media_player_t *);
+STUB_R_2(int, libvlc_media_player_set_rate, libvlc_media_player_t *, float);
+STUB_R_1(int, libvlc_media_player_is_seekable, libvlc_media_player_t *);
+STUB_R_1(int, libvlc_media_player_will_play, libvlc_media_player_t *);
 STUB_R_1(libvlc_audio_output_t*, libvlc_audio_output_list_get,	libvlc_instance_t *);
 STUB___1(libvlc_audio_output_list_release, libvlc_audio_output_t *);
 STUB_R_2(int, libvlc_audio_output_set, libvlc_media_player_t *, const char *);
+STUB_R_3(int, libvlc_media_parse_with_options, libvlc_media_t *, libvlc_media_parse_flag_t, int);
+STUB_R_1(libvlc_media_parsed_status_t, libvlc_media_get_parsed_status, libvlc_media_t *);
+STUB_R_2(unsigned, libvlc_media_tracks_get, libvlc_media_t *, libvlc_media_track_t ***);
+STUB___2(libvlc_media_tracks_release, libvlc_media_track_t **, unsigned);
+STUB_R_1(libvlc_time_t, libvlc_media_get_duration, libvlc_media_t *);
+STUB_R_2(const char *, libvlc_media_get_codec_description, libvlc_track_type_t, uint32_t);
+STUB___2(libvlc_media_add_option, libvlc_media_t *, const char *);
+STUB_R_2(char *, libvlc_media_get_meta, libvlc_media_t *, libvlc_meta_t);
+STUB___1(libvlc_free, void *);
+STUB___3(libvlc_log_set, libvlc_instance_t *, libvlc_log_cb, void *);
+STUB___1(libvlc_log_unset, libvlc_instance_t *);
+STUB___4(libvlc_log_get_context, const libvlc_log_t *, const char **, const char **, unsigned *);
+STUB___4(libvlc_log_get_object, const libvlc_log_t *, const char **, const char **, uintptr_t *);
+
+
+struct vlc_symbol {
+    const char *name;
+    void **ptr;
+};
+
+#define SYMBOL(func) { #func, (void **)&PTR_##func }
+
+static const struct vlc_symbol symbols[] = {
+    SYMBOL(libvlc_new),
+    SYMBOL(libvlc_release),
+    SYMBOL(libvlc_errmsg),
+    SYMBOL(libvlc_clearerr),
+    SYMBOL(libvlc_media_release),
+    SYMBOL(libvlc_media_new_path),
+    SYMBOL(libvlc_media_new_location),
+    SYMBOL(libvlc_media_get_user_data),
+    SYMBOL(libvlc_video_set_key_input),
+    SYMBOL(libvlc_video_set_mouse_input),
+    SYMBOL(libvlc_video_set_aspect_ratio),
+    SYMBOL(libvlc_video_set_crop_geometry),
+    SYMBOL(libvlc_event_attach),
+    SYMBOL(libvlc_event_detach),
+    SYMBOL(libvlc_audio_set_mute),
+    SYMBOL(libvlc_media_player_event_manager),
+    SYMBOL(libvlc_media_event_manager),
+    SYMBOL(libvlc_media_player_get_media),
+    SYMBOL(libvlc_media_player_is_playing),
+    SYMBOL(libvlc_media_player_new),
+    SYMBOL(libvlc_media_player_play),
+    SYMBOL(libvlc_media_player_release),
+    SYMBOL(libvlc_media_player_set_hwnd),
+    SYMBOL(libvlc_media_player_set_xwindow),
+    SYMBOL(libvlc_media_player_set_media),
+    SYMBOL(libvlc_media_player_stop),
+    SYMBOL(libvlc_media_player_set_pause),
+    SYMBOL(libvlc_media_player_get_length),
+    SYMBOL(libvlc_media_player_get_time),
+    SYMBOL(libvlc_media_player_set_time),
+    SYMBOL(libvlc_media_player_get_position),
+    SYMBOL(libvlc_media_player_set_position),
+    SYMBOL(libvlc_media_player_get_rate),
+    SYMBOL(libvlc_media_player_set_rate),
+    SYMBOL(libvlc_media_player_is_seekable),
+    SYMBOL(libvlc_media_player_will_play),
+    SYMBOL(libvlc_audio_output_list_get),
+    SYMBOL(libvlc_audio_output_list_release),
+    SYMBOL(libvlc_audio_output_set),
+    SYMBOL(libvlc_media_parse_with_options),
+    SYMBOL(libvlc_media_get_parsed_status),
+    SYMBOL(libvlc_media_tracks_get),
+    SYMBOL(libvlc_media_tracks_release),
+    SYMBOL(libvlc_media_get_duration),
+    SYMBOL(libvlc_media_get_codec_description),
+    SYMBOL(libvlc_media_add_option),
+    SYMBOL(libvlc_media_get_meta),
+    SYMBOL(libvlc_free),
+    SYMBOL(libvlc_log_set),
+    SYMBOL(libvlc_log_unset),
+    SYMBOL(libvlc_log_get_context),
+    SYMBOL(libvlc_log_get_object),
+    SYMBOL(libvlc_get_version),
+    SYMBOL(libvlc_get_compiler),
+};
+
+#undef SYMBOL
+
+#define SYMBOL_COUNT (sizeof(symbols) / sizeof(symbols[0]))
+
+/* Whether each of symbols was found by the last load_vlc_library. */
+static unsigned char found[SYMBOL_COUNT];
+
+int vlc_symbol_count(void)
+{
+    return SYMBOL_COUNT;
+}
 
+const char *vlc_symbol_name(int i)
+{
+    return symbols[i].name;
+}
 
-#define WIN32_LEAN_AND_MEAN
-#include <windows.h>
-
-int load_vlc_library(void)
+int vlc_symbol_found(int i)
+{
+    return found[i];
+}
+
+/* Loads the library at path and resolves every stub from it. Returns -1 with
+ * a description of the failure in err if the library can't be opened, or
+ * else the number of symbols it lacks, which vlc_symbol_found reports. The
+ * library is only kept open if it has them all. */
+int load_vlc_library(const char *path, char *err, size_t errlen)
 {
-    HMODULE lib = LoadLibrary("libvlc.dll");
+    void *lib = open_vlc_library(path, err, errlen);
+    int missing = 0;
+    size_t i;
 
     if (!lib)
-        return 0;
-
-#define LOAD(func) \
-    PTR_##func = (TYPE_##func)GetProcAddress(lib, #func)
-
-    LOAD(libvlc_new);
-    LOAD(libvlc_release);
-    LOAD(libvlc_errmsg);
-    LOAD(libvlc_clearerr);
-    LOAD(libvlc_media_release);
-    LOAD(libvlc_media_new_path);
-    LOAD(libvlc_media_new_location);
-    LOAD(libvlc_media_get_user_data);
-    LOAD(libvlc_video_set_key_input);
-    LOAD(libvlc_video_set_mouse_input);
-    LOAD(libvlc_event_attach);
-    LOAD(libvlc_event_detach);
-    LOAD(libvlc_audio_set_mute);
-    LOAD(libvlc_media_player_event_manager);
-    LOAD(libvlc_media_player_get_media);
-    LOAD(libvlc_media_player_is_playing);
-    LOAD(libvlc_media_player_new);
-    LOAD(libvlc_media_player_play);
-    LOAD(libvlc_media_player_release);
-    LOAD(libvlc_media_player_set_hwnd);
-    LOAD(libvlc_media_player_set_media);
-    LOAD(libvlc_media_player_stop);
-    LOAD(libvlc_audio_output_list_get);
-    LOAD(libvlc_audio_output_list_release);
-    LOAD(libvlc_audio_output_set);
-
-#undef LOAD
-
-    return 1;
-}
\ No newline at end of file
+        return -1;
+
+    for (i = 0; i < SYMBOL_COUNT; i++) {
+        *symbols[i].ptr = find_vlc_symbol(lib, symbols[i].name);
+        found[i] = *symbols[i].ptr != NULL;
+        if (!found[i])
+            missing++;
+    }
+
+    if (missing) {
+        /* Don't leave pointers into a library we are about to unload. */
+        for (i = 0; i < SYMBOL_COUNT; i++)
+            *symbols[i].ptr = NULL;
+        close_vlc_library(lib);
+    }
+
+    return missing;
+}
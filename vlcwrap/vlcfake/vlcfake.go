@@ -0,0 +1,496 @@
+// Package vlcfake provides a fake vlcwrap.MediaPlayer, so that code playing
+// media can be tested without libvlc. Fake players play clips from a Library
+// of known lengths, and only move on when the library is told time has
+// passed, delivering events much as libvlc would.
+package vlcfake
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	vlc "github.com/sammydre/golang-video-screensaver/vlcwrap"
+)
+
+var (
+	ErrNotFound = errors.New("no such clip")
+	ErrReleased = errors.New("player released")
+	ErrNoMedia  = errors.New("no media")
+)
+
+// State is what a fake player is doing.
+type State int
+
+const (
+	Stopped State = iota
+	Playing
+	Paused
+	Ended
+	Failed
+)
+
+func (s State) String() string {
+	switch s {
+	case Stopped:
+		return "stopped"
+	case Playing:
+		return "playing"
+	case Paused:
+		return "paused"
+	case Ended:
+		return "ended"
+	case Failed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// Clip is a clip in a Library.
+type Clip struct {
+	Length time.Duration
+	// Unseekable clips ignore SetTime.
+	Unseekable bool
+	// Broken clips load, but fail as soon as they are played.
+	Broken bool
+}
+
+// Library holds the clips fake players can play, and the players made from
+// it.
+type Library struct {
+	sync.Mutex
+
+	clips   map[string]Clip
+	players []*Player
+}
+
+func NewLibrary() *Library {
+	return &Library{clips: map[string]Clip{}}
+}
+
+// Add adds a clip to the library, which can then be loaded by location.
+func (l *Library) Add(location string, clip Clip) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.clips[location] = clip
+}
+
+// Remove takes a clip out of the library, so that loading it fails.
+func (l *Library) Remove(location string) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.clips, location)
+}
+
+func (l *Library) clip(location string) (Clip, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	clip, ok := l.clips[location]
+	return clip, ok
+}
+
+// NewPlayer creates a player playing clips from the library.
+func (l *Library) NewPlayer() *Player {
+	l.Lock()
+	defer l.Unlock()
+
+	p := &Player{
+		library:   l,
+		rate:      1,
+		callbacks: map[vlc.EventID]callback{},
+	}
+	l.players = append(l.players, p)
+	return p
+}
+
+// Players returns the players made from the library, in the order they were
+// made.
+func (l *Library) Players() []*Player {
+	l.Lock()
+	defer l.Unlock()
+
+	return append([]*Player(nil), l.players...)
+}
+
+// Advance moves every player on by d, as if that long had passed.
+func (l *Library) Advance(d time.Duration) {
+	for _, p := range l.Players() {
+		p.Advance(d)
+	}
+}
+
+// Media is media loaded into a fake player.
+type Media struct {
+	sync.Mutex
+
+	Location string
+	options  []string
+	released bool
+}
+
+func (m *Media) AddOption(option string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.options = append(m.options, option)
+	return nil
+}
+
+// Options returns the options added to the media.
+func (m *Media) Options() []string {
+	m.Lock()
+	defer m.Unlock()
+
+	return append([]string(nil), m.options...)
+}
+
+func (m *Media) hasOption(option string) bool {
+	for _, o := range m.Options() {
+		if o == option || strings.HasPrefix(o, option+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Media) Release() error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.released = true
+	return nil
+}
+
+// Released reports whether the media has been released.
+func (m *Media) Released() bool {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.released
+}
+
+type callback struct {
+	event    vlc.Event
+	callback vlc.EventCallback
+	userData interface{}
+}
+
+// Player is a fake vlcwrap.MediaPlayer. Its events are delivered on the
+// goroutine that causes them, without the player's lock held.
+type Player struct {
+	sync.Mutex
+
+	library       *Library
+	media         *Media
+	clip          Clip
+	state         State
+	time          time.Duration
+	rate          float64
+	aspectRatio   string
+	cropGeometry  string
+	released      bool
+	callbacks     map[vlc.EventID]callback
+	eventSequence vlc.EventID
+}
+
+var _ vlc.MediaPlayer = (*Player)(nil)
+
+func (p *Player) Load(location string, local bool) (vlc.MediaHandle, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.released {
+		return nil, ErrReleased
+	}
+
+	clip, ok := p.library.clip(location)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	p.media = &Media{Location: location}
+	p.clip = clip
+	p.state = Stopped
+	p.time = 0
+	p.rate = 1
+	return p.media, nil
+}
+
+// Play starts the current media, or opens it paused if it has the
+// ":start-paused" option.
+func (p *Player) Play() error {
+	p.Lock()
+	if p.released {
+		p.Unlock()
+		return ErrReleased
+	}
+	if p.media == nil {
+		p.Unlock()
+		return ErrNoMedia
+	}
+	if p.state == Playing || p.state == Paused {
+		p.Unlock()
+		return nil
+	}
+
+	var e event
+	switch {
+	case p.clip.Broken:
+		p.state = Failed
+		e = event{vlc.MediaPlayerEncounteredError, vlc.EncounteredErrorPayload{Message: "broken clip"}}
+	case p.media.hasOption(":start-paused"):
+		p.state = Paused
+		e = event{vlc.MediaPlayerPaused, nil}
+	default:
+		p.state = Playing
+		e = event{vlc.MediaPlayerPlaying, nil}
+	}
+	length := vlc.LengthChangedPayload{Length: p.clip.Length}
+	p.Unlock()
+
+	if e.event != vlc.MediaPlayerEncounteredError {
+		p.emit(event{vlc.MediaPlayerLengthChanged, length})
+	}
+	p.emit(e)
+	return nil
+}
+
+func (p *Player) SetPause(pause bool) error {
+	p.Lock()
+	var events []event
+	switch {
+	case pause && p.state == Playing:
+		p.state = Paused
+		events = append(events, event{vlc.MediaPlayerPaused, nil})
+	case !pause && p.state == Paused:
+		p.state = Playing
+		events = append(events, event{vlc.MediaPlayerPlaying, nil})
+	}
+	p.Unlock()
+
+	p.emit(events...)
+	return nil
+}
+
+func (p *Player) Stop() error {
+	p.Lock()
+	wasStopped := p.state == Stopped
+	p.state = Stopped
+	p.time = 0
+	p.Unlock()
+
+	if !wasStopped {
+		p.emit(event{vlc.MediaPlayerStopped, nil})
+	}
+	return nil
+}
+
+func (p *Player) IsPlaying() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.state == Playing
+}
+
+// State returns what the player is doing.
+func (p *Player) State() State {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.state
+}
+
+// Media returns the player's current media, if any.
+func (p *Player) Media() *Media {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.media
+}
+
+func (p *Player) IsSeekable() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.media != nil && !p.clip.Unseekable
+}
+
+// opened reports whether the clip has been opened, and so has a known
+// length and time. Must be called with the lock held.
+func (p *Player) opened() bool {
+	return p.state == Playing || p.state == Paused || p.state == Ended
+}
+
+func (p *Player) Length() (time.Duration, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.opened() {
+		return 0, nil
+	}
+	return p.clip.Length, nil
+}
+
+func (p *Player) Time() (time.Duration, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.opened() {
+		return 0, ErrNoMedia
+	}
+	return p.time, nil
+}
+
+func (p *Player) SetTime(t time.Duration) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.opened() && !p.clip.Unseekable {
+		p.time = t
+	}
+	return nil
+}
+
+func (p *Player) Rate() (float64, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.rate, nil
+}
+
+func (p *Player) SetRate(rate float64) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.rate = rate
+	return nil
+}
+
+func (p *Player) SetAspectRatio(aspect string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.aspectRatio = aspect
+	return nil
+}
+
+func (p *Player) SetCropGeometry(geometry string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.cropGeometry = geometry
+	return nil
+}
+
+func (p *Player) Events() (vlc.EventSource, error) {
+	return p, nil
+}
+
+func (p *Player) Attach(event vlc.Event, cb vlc.EventCallback, userData interface{}) (vlc.EventID, error) {
+	if cb == nil {
+		return 0, vlc.ErrInvalidEventCallback
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.eventSequence++
+	p.callbacks[p.eventSequence] = callback{event: event, callback: cb, userData: userData}
+	return p.eventSequence, nil
+}
+
+func (p *Player) Detach(eventIDs ...vlc.EventID) {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, id := range eventIDs {
+		delete(p.callbacks, id)
+	}
+}
+
+// Attached returns how many callbacks are attached.
+func (p *Player) Attached() int {
+	p.Lock()
+	defer p.Unlock()
+
+	return len(p.callbacks)
+}
+
+func (p *Player) Release() error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.released = true
+	p.state = Stopped
+	return nil
+}
+
+// Released reports whether the player has been released.
+func (p *Player) Released() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.released
+}
+
+// Advance moves the player on by d of real time, if it is playing, reaching
+// the end of the clip if it gets that far.
+func (p *Player) Advance(d time.Duration) {
+	p.Lock()
+	if p.state != Playing {
+		p.Unlock()
+		return
+	}
+
+	p.time += time.Duration(float64(d) * p.rate)
+	ended := p.time >= p.clip.Length
+	if ended {
+		p.time = p.clip.Length
+		p.state = Ended
+	}
+	events := []event{{vlc.MediaPlayerTimeChanged, vlc.TimeChangedPayload{Time: p.time}}}
+	if ended {
+		events = append(events, event{vlc.MediaPlayerEndReached, nil})
+	}
+	p.Unlock()
+
+	p.emit(events...)
+}
+
+// Fail makes the player fail, as if the clip turned out to be corrupt,
+// reporting message as the error.
+func (p *Player) Fail(message string) {
+	p.Lock()
+	p.state = Failed
+	p.Unlock()
+
+	p.emit(event{vlc.MediaPlayerEncounteredError, vlc.EncounteredErrorPayload{Message: message}})
+}
+
+// event is an event to deliver, with its payload.
+type event struct {
+	event   vlc.Event
+	payload interface{}
+}
+
+// emit calls the callbacks attached to each event in turn. It must be
+// called without the lock held, as callbacks may call back into the player.
+func (p *Player) emit(events ...event) {
+	for _, e := range events {
+		p.Lock()
+		var callbacks []callback
+		for id := vlc.EventID(1); id <= p.eventSequence; id++ {
+			if cb, ok := p.callbacks[id]; ok && cb.event == e.event {
+				callbacks = append(callbacks, cb)
+			}
+		}
+		p.Unlock()
+
+		for _, cb := range callbacks {
+			cb.callback(e.event, e.payload, cb.userData)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package vlcfake
+
+import (
+	"testing"
+	"time"
+
+	vlc "github.com/sammydre/golang-video-screensaver/vlcwrap"
+)
+
+func TestPlayerPlaysToTheEnd(t *testing.T) {
+	library := NewLibrary()
+	library.Add("clip.mp4", Clip{Length: 2 * time.Second})
+	player := library.NewPlayer()
+
+	var events []vlc.Event
+	manager, _ := player.Events()
+	for _, event := range []vlc.Event{vlc.MediaPlayerPlaying, vlc.MediaPlayerEndReached} {
+		if _, err := manager.Attach(event, func(event vlc.Event, payload interface{}, userData interface{}) {
+			events = append(events, event)
+		}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := player.Load("clip.mp4", true); err != nil {
+		t.Fatal(err)
+	}
+	if length, _ := player.Length(); length != 0 {
+		t.Errorf("Expected no length before the clip is opened, got %v", length)
+	}
+	player.Play()
+
+	library.Advance(time.Second)
+	if played, _ := player.Time(); played != time.Second || !player.IsPlaying() {
+		t.Errorf("Expected to be 1s in and playing, got %v", played)
+	}
+
+	player.SetRate(2)
+	library.Advance(time.Second)
+	if player.State() != Ended {
+		t.Errorf("Expected the clip to have ended at double speed, got %v", player.State())
+	}
+
+	if len(events) != 2 || events[0] != vlc.MediaPlayerPlaying || events[1] != vlc.MediaPlayerEndReached {
+		t.Errorf("Unexpected events %v", events)
+	}
+}
+
+func TestPlayerStartsPaused(t *testing.T) {
+	library := NewLibrary()
+	library.Add("clip.mp4", Clip{Length: time.Second})
+	player := library.NewPlayer()
+
+	media, _ := player.Load("clip.mp4", true)
+	media.AddOption(":start-paused")
+	player.Play()
+
+	library.Advance(time.Second)
+	if player.State() != Paused {
+		t.Fatalf("Expected the clip to be paused, got %v", player.State())
+	}
+	if length, _ := player.Length(); length != time.Second {
+		t.Errorf("Expected the paused clip's length to be known, got %v", length)
+	}
+
+	player.SetPause(false)
+	library.Advance(time.Second)
+	if player.State() != Ended {
+		t.Errorf("Expected the clip to have played once resumed, got %v", player.State())
+	}
+}
+
+func TestPlayerFailures(t *testing.T) {
+	library := NewLibrary()
+	library.Add("broken.mp4", Clip{Length: time.Second, Broken: true})
+	player := library.NewPlayer()
+
+	if _, err := player.Load("missing.mp4", true); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	var failed bool
+	events, _ := player.Events()
+	id, _ := events.Attach(vlc.MediaPlayerEncounteredError, func(event vlc.Event, payload interface{}, userData interface{}) {
+		failed = payload.(vlc.EncounteredErrorPayload).Message == "broken clip"
+	}, nil)
+
+	player.Load("broken.mp4", true)
+	player.Play()
+	if !failed || player.State() != Failed {
+		t.Errorf("Expected the broken clip to fail")
+	}
+
+	events.Detach(id)
+	if player.Attached() != 0 {
+		t.Errorf("Expected the callback to be detached")
+	}
+}
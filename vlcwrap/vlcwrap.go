@@ -30,18 +30,26 @@ package vlcwrap
 // for dynamic loading.
 
 // #cgo CFLAGS: -I ${SRCDIR}/../out/libvlc-3.0.16/build/x64/include
+// #cgo linux LDFLAGS: -ldl
 /*
 #include <stdlib.h>
 
 #include <vlc/vlc.h>
 
 extern void eventDispatch(libvlc_event_t*, void*);
-extern int load_vlc_library(void);
+extern int load_vlc_library(const char*, char*, size_t);
+extern int vlc_symbol_count(void);
+extern const char* vlc_symbol_name(int);
+extern int vlc_symbol_found(int);
 
 static inline int eventAttach(libvlc_event_manager_t* em, libvlc_event_type_t et, unsigned long userData) {
     return libvlc_event_attach(em, et, (void (*)(const libvlc_event_t*, void*))eventDispatch, (void*)(intptr_t)userData);
 }
 
+static inline libvlc_video_track_t* mediaTrackVideo(libvlc_media_track_t* track) {
+    return track->video;
+}
+
 static inline int eventDetach(libvlc_event_manager_t* em, libvlc_event_type_t et, unsigned long userData) {
     libvlc_event_detach(em, et, (void (*)(const libvlc_event_t*, void*))eventDispatch, (void*)(intptr_t)userData);
 }
@@ -49,8 +57,13 @@ static inline int eventDetach(libvlc_event_manager_t* em, libvlc_event_type_t et
 import "C"
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -58,10 +71,15 @@ type EventID uint64
 
 type Player struct {
 	player *C.libvlc_media_player_t
+	inst   *Instance
 }
 
 type Media struct {
 	media *C.libvlc_media_t
+	inst  *Instance
+	// owned media was created by us, and counts towards its instance's live
+	// objects until released.
+	owned bool
 }
 
 // Event represents an event that can occur inside libvlc.
@@ -70,10 +88,13 @@ type Event int
 // EventManager wraps a libvlc event manager.
 type EventManager struct {
 	manager *C.libvlc_event_manager_t
+	inst    *Instance
 }
 
-// EventCallback represents an event notification callback function.
-type EventCallback func(Event, interface{})
+// EventCallback represents an event notification callback function. It is
+// passed the event, its payload, which is one of the *Payload types or nil
+// for events without one, and the user data given to Attach.
+type EventCallback func(event Event, payload interface{}, userData interface{})
 
 type internalEventCallback func(*C.libvlc_event_t, interface{})
 
@@ -88,7 +109,6 @@ type eventRegistry struct {
 	sync.RWMutex
 
 	contexts map[EventID]*eventContext
-	sequence EventID
 }
 
 type objectContext struct {
@@ -102,10 +122,20 @@ type objectRegistry struct {
 	contexts map[objectID]*objectContext
 }
 
-type instance struct {
+// Instance is a libvlc instance, from which players and media are created.
+// Each has its own arguments, and must be released once everything created
+// from it has been.
+type Instance struct {
 	handle  *C.libvlc_instance_t
 	events  *eventRegistry
 	objects *objectRegistry
+
+	sync.Mutex
+	players int
+	media   int
+	// logSink is the id of the sink the instance's log messages go to, if
+	// SetLogger has given one.
+	logSink uintptr
 }
 
 type mediaData struct {
@@ -123,9 +153,15 @@ var (
 	ErrMissingEventManager  = errors.New("eventmanager TODO")
 	ErrInvalidEventCallback = errors.New("event TODO")
 	ErrModuleNotInitialized = errors.New("module not initialized")
+	ErrModuleInitialized    = errors.New("module already initialized")
+	ErrInstanceInUse        = errors.New("instance still in use")
 	ErrModuleInitialize     = errors.New("could not initialize module")
 	ErrLibraryLoad          = errors.New("could not load shared library")
 	ErrAudioOutputSet       = errors.New("audio output TODO")
+	ErrMediaParse           = errors.New("could not parse media")
+	ErrMediaParseTimeout    = errors.New("timed out parsing media")
+	ErrNoVideoTrack         = errors.New("media has no video track")
+	ErrRateUnsupported      = errors.New("playback rate not supported")
 )
 
 // Player events.
@@ -162,7 +198,19 @@ const (
 	MediaPlayerChapterChanged
 )
 
-var inst *instance = nil
+// defaultInstance is the instance used by Init, Release and the other
+// package-level functions.
+var defaultInstance *Instance
+
+// instances are those not yet released, to which events are dispatched.
+var instances = struct {
+	sync.RWMutex
+	live map[*Instance]bool
+}{live: map[*Instance]bool{}}
+
+// eventSequence numbers event attachments across every instance, so that
+// an event's id alone says which instance it belongs to.
+var eventSequence uint64
 
 func getError() error {
 	msg := C.libvlc_errmsg()
@@ -183,7 +231,7 @@ func errOrDefault(err, defaultErr error) error {
 	return defaultErr
 }
 
-func (i *instance) assertInit() error {
+func (i *Instance) assertInit() error {
 	if i == nil || i.handle == nil {
 		return ErrModuleNotInitialized
 	}
@@ -191,14 +239,151 @@ func (i *instance) assertInit() error {
 	return nil
 }
 
-// Init creates an instance of the libVLC module.
-// Must be called only once and the module instance must be released using
-// the Release function.
-func Init(args ...string) error {
-	if inst != nil {
+// InstanceInUseError is returned when releasing an instance that players or
+// media created from it are still using.
+type InstanceInUseError struct {
+	Players int
+	Media   int
+}
+
+func (e *InstanceInUseError) Error() string {
+	return fmt.Sprintf("%v: %d players and %d media not released", ErrInstanceInUse, e.Players, e.Media)
+}
+
+func (e *InstanceInUseError) Unwrap() error {
+	return ErrInstanceInUse
+}
+
+// inUse returns an InstanceInUseError if anything created from the instance
+// hasn't been released.
+func (i *Instance) inUse() error {
+	i.Lock()
+	defer i.Unlock()
+
+	if i.players > 0 || i.media > 0 {
+		return &InstanceInUseError{Players: i.players, Media: i.media}
+	}
+	return nil
+}
+
+// track counts players and media created from the instance, or released
+// when negative.
+func (i *Instance) track(players, media int) {
+	i.Lock()
+	defer i.Unlock()
+
+	i.players += players
+	i.media += media
+}
+
+// LibrarySearchPath lists directories to look for the libvlc shared library
+// in, in order, before leaving it to the system's own search. It must be set
+// before calling Init.
+var LibrarySearchPath []string
+
+// LibraryLoadError is returned by Init when libvlc can't be loaded, giving
+// the reason for each place it was looked for.
+type LibraryLoadError struct {
+	Attempts []string
+}
+
+func (e *LibraryLoadError) Error() string {
+	return ErrLibraryLoad.Error() + ": " + strings.Join(e.Attempts, "; ")
+}
+
+func (e *LibraryLoadError) Unwrap() error {
+	return ErrLibraryLoad
+}
+
+// MissingSymbolsError is returned by Init when a libvlc library was found,
+// but it lacks functions the wrapper needs, most likely because it is too
+// old.
+type MissingSymbolsError struct {
+	Library string
+	Symbols []string
+}
+
+func (e *MissingSymbolsError) Error() string {
+	return fmt.Sprintf("%v: %v is missing %v", ErrLibraryLoad, e.Library, strings.Join(e.Symbols, ", "))
+}
+
+func (e *MissingSymbolsError) Unwrap() error {
+	return ErrLibraryLoad
+}
+
+// loadLibraryErrorSize is plenty for any path and error message the loader
+// will give us.
+const loadLibraryErrorSize = 1024
+
+// libraryLoaded is set once loadLibrary has succeeded, after which every
+// stub is safe to call.
+var (
+	libraryLoaded bool
+	libraryMutex  sync.Mutex
+)
+
+// loadLibrary tries libraryName in each directory of LibrarySearchPath,
+// then by name alone, until one loads with every function we need. If none
+// do, but one could at least be opened, the MissingSymbolsError for it is
+// returned, as that is the more useful thing to know.
+func loadLibrary() error {
+	libraryMutex.Lock()
+	defer libraryMutex.Unlock()
+
+	if libraryLoaded {
 		return nil
 	}
 
+	var candidates []string
+	for _, dir := range LibrarySearchPath {
+		candidates = append(candidates, filepath.Join(dir, libraryName))
+	}
+	candidates = append(candidates, libraryName)
+
+	errBuf := (*C.char)(C.calloc(loadLibraryErrorSize, 1))
+	defer C.free(unsafe.Pointer(errBuf))
+
+	var loadErr LibraryLoadError
+	var missingErr *MissingSymbolsError
+
+	for _, candidate := range candidates {
+		cPath := C.CString(candidate)
+		missing := C.load_vlc_library(cPath, errBuf, loadLibraryErrorSize)
+		C.free(unsafe.Pointer(cPath))
+
+		switch {
+		case missing == 0:
+			libraryLoaded = true
+			return nil
+
+		case missing < 0:
+			loadErr.Attempts = append(loadErr.Attempts, C.GoString(errBuf))
+
+		default:
+			err := &MissingSymbolsError{Library: candidate}
+			for i := C.int(0); i < C.vlc_symbol_count(); i++ {
+				if C.vlc_symbol_found(i) == 0 {
+					err.Symbols = append(err.Symbols, C.GoString(C.vlc_symbol_name(i)))
+				}
+			}
+
+			loadErr.Attempts = append(loadErr.Attempts, err.Error())
+			if missingErr == nil {
+				missingErr = err
+			}
+		}
+	}
+
+	if missingErr != nil {
+		return missingErr
+	}
+	return &loadErr
+}
+
+// NewInstance loads libvlc, if it hasn't been already, and creates an
+// instance of it with the given arguments. It must be released with
+// Release.
+func NewInstance(args ...string) (*Instance, error) {
 	argc := len(args)
 	argv := make([]*C.char, argc)
 
@@ -212,50 +397,110 @@ func Init(args ...string) error {
 	}()
 
 	// Hack: new code: add dynamic library load
-	if C.load_vlc_library() == 0 {
-		// FIXME: error msg
-		return ErrLibraryLoad
+	if err := loadLibrary(); err != nil {
+		return nil, err
+	}
+
+	if version := Version(); !version.AtLeast(MinimumVersion) {
+		return nil, &UnsupportedVersionError{Version: version, Runtime: RuntimeVersion()}
 	}
 	// End: new code
 
 	handle := C.libvlc_new(C.int(argc), *(***C.char)(unsafe.Pointer(&argv)))
 	if handle == nil {
-		return errOrDefault(getError(), ErrModuleInitialize)
+		return nil, errOrDefault(getError(), ErrModuleInitialize)
 	}
 
-	inst = &instance{
+	i := &Instance{
 		handle:  handle,
 		events:  newEventRegistry(),
 		objects: newObjectRegistry(),
 	}
 
+	instances.Lock()
+	instances.live[i] = true
+	instances.Unlock()
+
+	return i, nil
+}
+
+// Release destroys the instance. It fails with an InstanceInUseError,
+// leaving the instance as it is, if players or media created from it have
+// not been released yet.
+func (i *Instance) Release() error {
+	if err := i.assertInit(); err != nil {
+		return nil
+	}
+	if err := i.inUse(); err != nil {
+		return err
+	}
+
+	instances.Lock()
+	delete(instances.live, i)
+	instances.Unlock()
+
+	C.libvlc_release(i.handle)
+	i.handle = nil
+
+	if i.logSink != 0 {
+		removeLogSink(i.logSink)
+		i.logSink = 0
+	}
+
+	return getError()
+}
+
+// Init creates the default instance of the libVLC module, which the
+// package-level functions use. It must be released using the Release
+// function before it can be created again.
+func Init(args ...string) error {
+	if defaultInstance != nil {
+		return ErrModuleInitialized
+	}
+
+	i, err := NewInstance(args...)
+	if err != nil {
+		return err
+	}
+
+	defaultInstance = i
 	return nil
 }
 
-// Release destroys the instance created by the Init function.
+// Release destroys the default instance created by the Init function. Like
+// Instance.Release, it fails if players or media are still using it.
 func Release() error {
-	if inst == nil {
+	if defaultInstance == nil {
 		return nil
 	}
 
-	C.libvlc_release(inst.handle)
-	inst = nil
+	if err := defaultInstance.Release(); err != nil {
+		return err
+	}
+	defaultInstance = nil
 
-	return getError()
+	return nil
 }
 
-// NewPlayer creates an instance of a single-media player.
+// NewPlayer creates an instance of a single-media player, using the
+// default instance.
 func NewPlayer() (*Player, error) {
-	if err := inst.assertInit(); err != nil {
+	return defaultInstance.NewPlayer()
+}
+
+// NewPlayer creates an instance of a single-media player.
+func (i *Instance) NewPlayer() (*Player, error) {
+	if err := i.assertInit(); err != nil {
 		return nil, err
 	}
 
-	player := C.libvlc_media_player_new(inst.handle)
+	player := C.libvlc_media_player_new(i.handle)
 	if player == nil {
 		return nil, errOrDefault(getError(), ErrPlayerCreate)
 	}
 
-	return &Player{player: player}, nil
+	i.track(1, 0)
+	return &Player{player: player, inst: i}, nil
 }
 
 func (p *Player) assertInit() error {
@@ -281,6 +526,16 @@ func (p *Player) SetHWND(hwnd uintptr) error {
 	return getError()
 }
 
+// SetXWindow sets the X11 window the player should render into, on Linux.
+func (p *Player) SetXWindow(window uint32) error {
+	if err := p.assertInit(); err != nil {
+		return err
+	}
+
+	C.libvlc_media_player_set_xwindow(p.player, C.uint32_t(window))
+	return getError()
+}
+
 func boolToInt(value bool) int {
 	if value {
 		return 1
@@ -323,14 +578,58 @@ func (p *Player) SetMouseInput(enable bool) error {
 	return getError()
 }
 
+// SetAspectRatio overrides the shape the video is displayed at, given as
+// "width:height". An empty string restores the video's own aspect ratio.
+func (p *Player) SetAspectRatio(aspect string) error {
+	if err := p.assertInit(); err != nil {
+		return err
+	}
+
+	var cAspect *C.char
+	if len(aspect) > 0 {
+		cAspect = C.CString(aspect)
+		defer C.free(unsafe.Pointer(cAspect))
+	}
+
+	C.libvlc_video_set_aspect_ratio(p.player, cAspect)
+	return getError()
+}
+
+// SetCropGeometry crops the video, given as "width:height" to crop to that
+// aspect ratio. An empty string turns cropping off.
+func (p *Player) SetCropGeometry(geometry string) error {
+	if err := p.assertInit(); err != nil {
+		return err
+	}
+
+	var cGeometry *C.char
+	if len(geometry) > 0 {
+		cGeometry = C.CString(geometry)
+		defer C.free(unsafe.Pointer(cGeometry))
+	}
+
+	C.libvlc_video_set_crop_geometry(p.player, cGeometry)
+	return getError()
+}
+
 // LoadMediaFromPath loads the media located at the specified path and sets
 // it as the current media of the player.
 func (p *Player) LoadMediaFromPath(path string) (*Media, error) {
 	return p.loadMedia(path, true)
 }
 
+// LoadMediaFromURL loads the media located at the specified URL and sets
+// it as the current media of the player.
+func (p *Player) LoadMediaFromURL(url string) (*Media, error) {
+	return p.loadMedia(url, false)
+}
+
 func (p *Player) loadMedia(path string, local bool) (*Media, error) {
-	m, err := newMedia(path, local)
+	if err := p.assertInit(); err != nil {
+		return nil, err
+	}
+
+	m, err := p.inst.newMedia(path, local)
 	if err != nil {
 		return nil, err
 	}
@@ -343,8 +642,34 @@ func (p *Player) loadMedia(path string, local bool) (*Media, error) {
 	return m, nil
 }
 
-func newMedia(path string, local bool) (*Media, error) {
-	if err := inst.assertInit(); err != nil {
+// NewMediaFromPath creates a media instance for the file at path from the
+// default instance, without attaching it to a player. It must be released
+// with Release.
+func NewMediaFromPath(path string) (*Media, error) {
+	return defaultInstance.newMedia(path, true)
+}
+
+// NewMediaFromURL creates a media instance for url from the default
+// instance, without attaching it to a player. It must be released with
+// Release.
+func NewMediaFromURL(url string) (*Media, error) {
+	return defaultInstance.newMedia(url, false)
+}
+
+// NewMediaFromPath creates a media instance for the file at path, without
+// attaching it to a player. It must be released with Release.
+func (i *Instance) NewMediaFromPath(path string) (*Media, error) {
+	return i.newMedia(path, true)
+}
+
+// NewMediaFromURL creates a media instance for url, without attaching it to
+// a player. It must be released with Release.
+func (i *Instance) NewMediaFromURL(url string) (*Media, error) {
+	return i.newMedia(url, false)
+}
+
+func (i *Instance) newMedia(path string, local bool) (*Media, error) {
+	if err := i.assertInit(); err != nil {
 		return nil, err
 	}
 
@@ -357,16 +682,17 @@ func newMedia(path string, local bool) (*Media, error) {
 			return nil, err
 		}
 
-		media = C.libvlc_media_new_path(inst.handle, cPath)
+		media = C.libvlc_media_new_path(i.handle, cPath)
 	} else {
-		media = C.libvlc_media_new_location(inst.handle, cPath)
+		media = C.libvlc_media_new_location(i.handle, cPath)
 	}
 
 	if media == nil {
 		return nil, errOrDefault(getError(), ErrMediaCreate)
 	}
 
-	return &Media{media: media}, nil
+	i.track(0, 1)
+	return &Media{media: media, inst: i, owned: true}, nil
 }
 
 func (p *Player) setMedia(m *Media) error {
@@ -447,10 +773,117 @@ func (p *Player) IsPlaying() bool {
 	return C.libvlc_media_player_is_playing(p.player) != 0
 }
 
+// Length returns the length of the current media, or 0 if it isn't known.
+func (p *Player) Length() (time.Duration, error) {
+	if err := p.assertInit(); err != nil {
+		return 0, err
+	}
+
+	ms := C.libvlc_media_player_get_length(p.player)
+	if ms < 0 {
+		return 0, errOrDefault(getError(), ErrMediaNotInitialized)
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Time returns how far into the current media playback is.
+func (p *Player) Time() (time.Duration, error) {
+	if err := p.assertInit(); err != nil {
+		return 0, err
+	}
+
+	ms := C.libvlc_media_player_get_time(p.player)
+	if ms < 0 {
+		return 0, errOrDefault(getError(), ErrMediaNotInitialized)
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// SetTime seeks to the given time into the current media. This only works
+// once the media is playing, and has no effect if it isn't seekable.
+func (p *Player) SetTime(t time.Duration) error {
+	if err := p.assertInit(); err != nil {
+		return err
+	}
+
+	C.libvlc_media_player_set_time(p.player, C.libvlc_time_t(t/time.Millisecond))
+	return getError()
+}
+
+// Position returns how far into the current media playback is, as a
+// proportion of its length between 0 and 1.
+func (p *Player) Position() (float64, error) {
+	if err := p.assertInit(); err != nil {
+		return 0, err
+	}
+
+	pos := C.libvlc_media_player_get_position(p.player)
+	if pos < 0 {
+		return 0, errOrDefault(getError(), ErrMediaNotInitialized)
+	}
+
+	return float64(pos), nil
+}
+
+// SetPosition seeks to a proportion of the way through the current media,
+// between 0 and 1. As with SetTime, the media must be playing and seekable.
+func (p *Player) SetPosition(pos float64) error {
+	if err := p.assertInit(); err != nil {
+		return err
+	}
+
+	C.libvlc_media_player_set_position(p.player, C.float(pos))
+	return getError()
+}
+
+// Rate returns the playback speed, where 1 is normal speed.
+func (p *Player) Rate() (float64, error) {
+	if err := p.assertInit(); err != nil {
+		return 0, err
+	}
+
+	return float64(C.libvlc_media_player_get_rate(p.player)), nil
+}
+
+// SetRate changes the playback speed, where 1 is normal speed. Not all
+// media can be played at every speed.
+func (p *Player) SetRate(rate float64) error {
+	if err := p.assertInit(); err != nil {
+		return err
+	}
+
+	if C.libvlc_media_player_set_rate(p.player, C.float(rate)) < 0 {
+		return errOrDefault(getError(), ErrRateUnsupported)
+	}
+
+	return nil
+}
+
+// IsSeekable reports whether the current media can be seeked within.
+func (p *Player) IsSeekable() bool {
+	if err := p.assertInit(); err != nil {
+		return false
+	}
+
+	return C.libvlc_media_player_is_seekable(p.player) != 0
+}
+
+// WillPlay reports whether the player is able to play its current media.
+func (p *Player) WillPlay() bool {
+	if err := p.assertInit(); err != nil {
+		return false
+	}
+
+	return C.libvlc_media_player_will_play(p.player) != 0
+}
+
 // newEventManager returns a new event manager instance.
-func newEventManager(manager *C.libvlc_event_manager_t) *EventManager {
+func newEventManager(manager *C.libvlc_event_manager_t, inst *Instance) *EventManager {
 	return &EventManager{
 		manager: manager,
+		inst:    inst,
 	}
 }
 
@@ -465,7 +898,7 @@ func (p *Player) EventManager() (*EventManager, error) {
 		return nil, ErrMissingEventManager
 	}
 
-	return newEventManager(manager), nil
+	return newEventManager(manager, p.inst), nil
 }
 
 // Attach registers a callback for an event notification.
@@ -475,17 +908,17 @@ func (em *EventManager) Attach(event Event, callback EventCallback, userData int
 
 // Detach unregisters the specified event notification.
 func (em *EventManager) Detach(eventIDs ...EventID) {
-	if err := inst.assertInit(); err != nil {
+	if err := em.inst.assertInit(); err != nil {
 		return
 	}
 
 	for _, eventID := range eventIDs {
-		ctx, ok := inst.events.get(eventID)
+		ctx, ok := em.inst.events.get(eventID)
 		if !ok {
 			continue
 		}
 
-		inst.events.remove(eventID)
+		em.inst.events.remove(eventID)
 		C.eventDetach(em.manager, C.libvlc_event_type_t(ctx.event), C.ulong(eventID))
 	}
 }
@@ -493,14 +926,14 @@ func (em *EventManager) Detach(eventIDs ...EventID) {
 // attach registers callbacks for an event notification.
 func (em *EventManager) attach(event Event, externalCallback EventCallback,
 	internalCallback internalEventCallback, userData interface{}) (EventID, error) {
-	if err := inst.assertInit(); err != nil {
+	if err := em.inst.assertInit(); err != nil {
 		return 0, err
 	}
 	if externalCallback == nil && internalCallback == nil {
 		return 0, ErrInvalidEventCallback
 	}
 
-	id := inst.events.add(event, externalCallback, internalCallback, userData)
+	id := em.inst.events.add(event, externalCallback, internalCallback, userData)
 	if C.eventAttach(em.manager, C.libvlc_event_type_t(event), C.ulong(id)) != 0 {
 		return 0, getError()
 	}
@@ -516,10 +949,9 @@ func newEventRegistry() *eventRegistry {
 
 func (er *eventRegistry) add(event Event, externalCallback EventCallback,
 	internalCallback internalEventCallback, userData interface{}) EventID {
-	er.Lock()
+	id := EventID(atomic.AddUint64(&eventSequence, 1))
 
-	er.sequence++
-	id := er.sequence
+	er.Lock()
 
 	er.contexts[id] = &eventContext{
 		event:            event,
@@ -534,18 +966,14 @@ func (er *eventRegistry) add(event Event, externalCallback EventCallback,
 
 //export eventDispatch
 func eventDispatch(event *C.libvlc_event_t, userData unsafe.Pointer) {
-	if err := inst.assertInit(); err != nil {
-		return
-	}
-
-	ctx, ok := inst.events.get(EventID(uintptr(userData)))
+	ctx, ok := findEvent(EventID(uintptr(userData)))
 	if !ok {
 		return
 	}
 
 	// Execute external callback.
 	if ctx.externalCallback != nil {
-		ctx.externalCallback(ctx.event, ctx.userData)
+		ctx.externalCallback(ctx.event, eventPayload(ctx.event, event), ctx.userData)
 	}
 
 	// Execute internal callback.
@@ -554,6 +982,35 @@ func eventDispatch(event *C.libvlc_event_t, userData unsafe.Pointer) {
 	}
 }
 
+// findEvent finds the context of an event attached to any live instance.
+func findEvent(id EventID) (*eventContext, bool) {
+	instances.RLock()
+	defer instances.RUnlock()
+
+	for i := range instances.live {
+		if ctx, ok := i.events.get(id); ok {
+			return ctx, true
+		}
+	}
+	return nil, false
+}
+
+// eventPayload decodes the payload of a libvlc event.
+func eventPayload(eventType Event, event *C.libvlc_event_t) interface{} {
+	if eventType == MediaPlayerEncounteredError {
+		var message string
+		if err := getError(); err != nil {
+			message = err.Error()
+		}
+		return EncounteredErrorPayload{Message: message}
+	}
+
+	// cgo sees the union as an array of its bytes.
+	return decodeEventPayload(eventType, event.u[:], func(ptr unsafe.Pointer) string {
+		return C.GoString((*C.char)(ptr))
+	})
+}
+
 func (er *eventRegistry) get(id EventID) (*eventContext, bool) {
 	if id == 0 {
 		return nil, false
@@ -576,6 +1033,20 @@ func (er *eventRegistry) remove(id EventID) {
 	er.Unlock()
 }
 
+// EventManager returns the event manager responsible for the media.
+func (m *Media) EventManager() (*EventManager, error) {
+	if err := m.assertInit(); err != nil {
+		return nil, err
+	}
+
+	manager := C.libvlc_media_event_manager(m.media)
+	if manager == nil {
+		return nil, ErrMissingEventManager
+	}
+
+	return newEventManager(manager, m.inst), nil
+}
+
 // Media returns the current media of the player, if one exists.
 func (p *Player) Media() (*Media, error) {
 	if err := p.assertInit(); err != nil {
@@ -591,7 +1062,7 @@ func (p *Player) Media() (*Media, error) {
 	// the reference count increased by libvlc_media_player_get_media.
 	C.libvlc_media_release(media)
 
-	return &Media{media}, nil
+	return &Media{media: media, inst: p.inst}, nil
 }
 
 // Stop cancels the currently playing media, if there is one.
@@ -604,6 +1075,18 @@ func (p *Player) Stop() error {
 	return getError()
 }
 
+// SetPause pauses or resumes the current media. Media loaded with the
+// ":start-paused" option can be played to open and buffer it, then resumed
+// with SetPause(false) when it is wanted.
+func (p *Player) SetPause(pause bool) error {
+	if err := p.assertInit(); err != nil {
+		return err
+	}
+
+	C.libvlc_media_player_set_pause(p.player, C.int(boolToInt(pause)))
+	return getError()
+}
+
 // Release destroys the media player instance.
 func (p *Player) Release() error {
 	if err := p.assertInit(); err != nil {
@@ -612,17 +1095,18 @@ func (p *Player) Release() error {
 
 	C.libvlc_media_player_release(p.player)
 	p.player = nil
+	p.inst.track(-1, 0)
 
 	return getError()
 }
 
 func (m *Media) getUserData() (objectID, *mediaData) {
-	if err := inst.assertInit(); err != nil {
+	if err := m.inst.assertInit(); err != nil {
 		return nil, nil
 	}
 	id := C.libvlc_media_get_user_data(m.media)
 
-	obj, ok := inst.objects.get(id)
+	obj, ok := m.inst.objects.get(id)
 	if !ok {
 		return nil, nil
 	}
@@ -641,8 +1125,8 @@ func (m *Media) deleteUserData() {
 		return
 	}
 
-	inst.objects.decRefs(data.readerID)
-	inst.objects.decRefs(id)
+	m.inst.objects.decRefs(data.readerID)
+	m.inst.objects.decRefs(id)
 }
 
 func (m *Media) release() {
@@ -652,6 +1136,11 @@ func (m *Media) release() {
 	// Delete media.
 	C.libvlc_media_release(m.media)
 	m.media = nil
+
+	if m.owned {
+		m.inst.track(0, -1)
+		m.owned = false
+	}
 }
 
 // Release destroys the media instance.
@@ -664,6 +1153,216 @@ func (m *Media) Release() error {
 	return getError()
 }
 
+// ParseAsync starts reading the media's metadata and track information, if
+// it is a local file, and returns straight away. libvlc gives up after
+// timeout, or never if it is 0. The media's event manager sends
+// MediaParsedChanged when it has finished, after which ParsedStatus says
+// how it went.
+func (m *Media) ParseAsync(timeout time.Duration) error {
+	if err := m.assertInit(); err != nil {
+		return err
+	}
+
+	if C.libvlc_media_parse_with_options(m.media, C.libvlc_media_parse_local, C.int(timeout/time.Millisecond)) != 0 {
+		return errOrDefault(getError(), ErrMediaParse)
+	}
+
+	return nil
+}
+
+// ParsedStatus says how parsing the media went, or is 0 if it hasn't
+// finished.
+func (m *Media) ParsedStatus() ParsedStatus {
+	if err := m.assertInit(); err != nil {
+		return 0
+	}
+
+	return ParsedStatus(C.libvlc_media_get_parsed_status(m.media))
+}
+
+// Parse reads the media's metadata and track information, if it is a local
+// file, waiting up to timeout for libvlc to finish.
+func (m *Media) Parse(timeout time.Duration) error {
+	events, err := m.EventManager()
+	if err != nil {
+		return err
+	}
+
+	parsed := make(chan struct{}, 1)
+	id, err := events.Attach(MediaParsedChanged, func(Event, interface{}, interface{}) {
+		select {
+		case parsed <- struct{}{}:
+		default:
+		}
+	}, nil)
+	if err != nil {
+		return err
+	}
+	defer events.Detach(id)
+
+	if err := m.ParseAsync(timeout); err != nil {
+		return err
+	}
+
+	// libvlc enforces the timeout itself; ours is only a backstop, in case
+	// the event is missed.
+	var backstop <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout + time.Second)
+		defer timer.Stop()
+		backstop = timer.C
+	}
+	check := time.NewTicker(100 * time.Millisecond)
+	defer check.Stop()
+
+	for {
+		if status := m.ParsedStatus(); status != 0 {
+			return parsedStatusError(status)
+		}
+
+		select {
+		case <-parsed:
+		case <-check.C:
+		case <-backstop:
+			return ErrMediaParseTimeout
+		}
+	}
+}
+
+// Meta returns a piece of the media's metadata, or "" if it has none. The
+// media must have been parsed first.
+func (m *Media) Meta(key MetaType) (string, error) {
+	if err := m.assertInit(); err != nil {
+		return "", err
+	}
+
+	value := C.libvlc_media_get_meta(m.media, C.libvlc_meta_t(key))
+	if value == nil {
+		return "", nil
+	}
+	defer C.libvlc_free(unsafe.Pointer(value))
+
+	return C.GoString(value), nil
+}
+
+// AddOption adds an option, such as ":input-repeat=65535", to the media. It
+// applies the next time the media is played.
+func (m *Media) AddOption(option string) error {
+	if err := m.assertInit(); err != nil {
+		return err
+	}
+
+	cOption := C.CString(option)
+	defer C.free(unsafe.Pointer(cOption))
+
+	C.libvlc_media_add_option(m.media, cOption)
+	return getError()
+}
+
+// Tracks returns the media's elementary streams. The media must have been
+// parsed first.
+func (m *Media) Tracks() ([]Track, error) {
+	if err := m.assertInit(); err != nil {
+		return nil, err
+	}
+
+	var tracks **C.libvlc_media_track_t
+	count := C.libvlc_media_tracks_get(m.media, &tracks)
+	if count == 0 || tracks == nil {
+		return nil, nil
+	}
+	defer C.libvlc_media_tracks_release(tracks, count)
+
+	ret := make([]Track, 0, count)
+	for _, track := range (*[1 << 16]*C.libvlc_media_track_t)(unsafe.Pointer(tracks))[:count:count] {
+		t := Track{
+			Type:             TrackType(track.i_type),
+			ID:               int(track.i_id),
+			Codec:            fourcc(uint32(track.i_codec)),
+			CodecDescription: C.GoString(C.libvlc_media_get_codec_description(track.i_type, track.i_codec)),
+			Bitrate:          uint(track.i_bitrate),
+			Language:         C.GoString(track.psz_language),
+			Description:      C.GoString(track.psz_description),
+		}
+
+		if track.i_type == C.libvlc_track_video {
+			if video := C.mediaTrackVideo(track); video != nil {
+				t.Video = &VideoTrack{
+					Width:        uint(video.i_width),
+					Height:       uint(video.i_height),
+					SARNum:       uint(video.i_sar_num),
+					SARDen:       uint(video.i_sar_den),
+					FrameRateNum: uint(video.i_frame_rate_num),
+					FrameRateDen: uint(video.i_frame_rate_den),
+					Orientation:  VideoOrientation(video.i_orientation),
+				}
+			}
+		}
+
+		ret = append(ret, t)
+	}
+
+	return ret, nil
+}
+
+// videoTrack returns the media's first video track that has a size,
+// returning ErrNoVideoTrack if there is none. The media must have been
+// parsed first.
+func (m *Media) videoTrack() (Track, error) {
+	tracks, err := m.Tracks()
+	if err != nil {
+		return Track{}, err
+	}
+
+	for _, track := range tracks {
+		if track.Video != nil && track.Video.Width != 0 && track.Video.Height != 0 {
+			return track, nil
+		}
+	}
+
+	return Track{}, ErrNoVideoTrack
+}
+
+// VideoSize returns the display size of the media's first video track,
+// allowing for non-square pixels and rotation. The media must have been
+// parsed first.
+func (m *Media) VideoSize() (uint, uint, error) {
+	track, err := m.videoTrack()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	width, height := track.Video.DisplaySize()
+	return width, height, nil
+}
+
+// VideoCodec returns a description of the codec of the media's first video
+// track, e.g. "H264 - MPEG-4 AVC (part 10)". The media must have been parsed
+// first.
+func (m *Media) VideoCodec() (string, error) {
+	track, err := m.videoTrack()
+	if err != nil {
+		return "", err
+	}
+
+	return track.CodecDescription, nil
+}
+
+// Duration returns the length of the media, or 0 if it isn't known. The
+// media must have been parsed first.
+func (m *Media) Duration() (time.Duration, error) {
+	if err := m.assertInit(); err != nil {
+		return 0, err
+	}
+
+	ms := C.libvlc_media_get_duration(m.media)
+	if ms < 0 {
+		return 0, nil
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
 func newObjectRegistry() *objectRegistry {
 	return &objectRegistry{
 		contexts: map[objectID]*objectContext{},
@@ -707,8 +1406,18 @@ func (or *objectRegistry) decRefs(id objectID) {
 //////////////////////////////////////////////////////////////////////////////
 // Sam was here
 
+// AudioOutputList lists the default instance's audio outputs.
 func AudioOutputList() []string {
-	audioOutputList := C.libvlc_audio_output_list_get(inst.handle)
+	return defaultInstance.AudioOutputList()
+}
+
+// AudioOutputList lists the names of the audio outputs available.
+func (i *Instance) AudioOutputList() []string {
+	if err := i.assertInit(); err != nil {
+		return nil
+	}
+
+	audioOutputList := C.libvlc_audio_output_list_get(i.handle)
 	defer C.libvlc_audio_output_list_release(audioOutputList)
 
 	var ret []string